@@ -8,9 +8,14 @@ import (
 
 	"github.com/alpacahq/alpaca-trade-api-go/v3/alpaca"
 	"github.com/alpacahq/alpaca-trade-api-go/v3/marketdata"
-	"github.com/jmvdr-iscte/TradingBotCli/enums"
+	"github.com/jmvdr-iscte/TradingBotCli/cash"
+	"github.com/jmvdr-iscte/TradingBotCli/compliance"
 	"github.com/jmvdr-iscte/TradingBotCli/initialize"
+	"github.com/jmvdr-iscte/TradingBotCli/logger"
+	"github.com/jmvdr-iscte/TradingBotCli/models"
+	"github.com/jmvdr-iscte/TradingBotCli/risk"
 	"github.com/jmvdr-iscte/TradingBotCli/utils"
+	"github.com/rs/zerolog/log"
 	"github.com/shopspring/decimal"
 )
 
@@ -21,13 +26,30 @@ const (
 	minimalShortingBuyingPower = 2000.0
 	dayTradinglimit            = 3
 	PDTEquity                  = 25000.0
+	fractionalDecimals         = 9
+	accountActive              = "ACTIVE"
+	flatPollInterval           = 500 * time.Millisecond
+	marketFillWait             = 10 * time.Second
+	tradeOrderPrefix           = "tradebot-"
+	cashLookback               = 7 * 24 * time.Hour
 )
 
+var one = decimal.NewFromInt(1)
+
 // A AlpacaClient serves as the client who interacts with the Alpaca API,
 // it can interact via a tradeClient and a dataClient.
 type AlpacaClient struct {
 	tradeClient *alpaca.Client
 	dataClient  *marketdata.Client
+	onTrade     func(symbol string, qty decimal.Decimal, side alpaca.Side, opening bool)
+	dryRun      bool
+	assets      *assetCache
+	execution   *initialize.ExecutionConfig
+	extended    *initialize.ExtendedConfig
+	onSoftStop  func(stop models.SoftStop)
+	compliance  *compliance.Engine
+	lastTrade   func(symbol string) (time.Time, error)
+	cash        *cash.Ledger
 }
 
 // LoadClient returns a pointer to the AlpacaClient
@@ -47,12 +69,55 @@ func LoadClient() *AlpacaClient {
 			APIKey:    configs.ID,
 			APISecret: configs.Secret,
 		}),
+
+		assets:    newAssetCache(),
+		execution: initialize.LoadExecutionConfigs(),
+		extended:  initialize.LoadExtendedConfigs(),
 	}
 }
 
+// OnTrade registers a function that is called every time an order is filled, opening
+// is true for the orders that open or add to a position.
+func (client *AlpacaClient) OnTrade(fn func(symbol string, qty decimal.Decimal, side alpaca.Side, opening bool)) {
+	client.onTrade = fn
+}
+
+// SetDryRun enables or disables the dry-run mode. In dry-run mode the orders are
+// only printed and never sent to the API.
+func (client *AlpacaClient) SetDryRun(dry_run bool) {
+	client.dryRun = dry_run
+}
+
 // ClosePositions returns an error if we were not able to connect to the API,
 // otherwise it returns nil.
 func (client *AlpacaClient) ClosePositions() error {
+	if client.dryRun {
+		positions, err := client.tradeClient.GetPositions()
+		if err != nil {
+			return fmt.Errorf("unable to get positions %w", err)
+		}
+		for _, position := range positions {
+			log.Info().Str(logger.Symbol, position.Symbol).Str("qty", position.Qty.String()).Str("side", position.Side).
+				Msg("[dry-run] would close the position")
+		}
+		return nil
+	}
+
+	// The positions can only be closed with limit orders outside the regular session, and
+	// the sales of a cash account are closed one by one to record their settlement.
+	if client.inExtendedSession() || client.cash != nil {
+		positions, err := client.tradeClient.GetPositions()
+		if err != nil {
+			return fmt.Errorf("unable to get positions %w", err)
+		}
+		for _, position := range positions {
+			if err := client.ClosePosition(position.Symbol); err != nil {
+				return fmt.Errorf("unable to close %s %w", position.Symbol, err)
+			}
+		}
+		return nil
+	}
+
 	req := alpaca.CloseAllPositionsRequest{
 		CancelOrders: true,
 	}
@@ -63,37 +128,202 @@ func (client *AlpacaClient) ClosePositions() error {
 	return nil
 }
 
+// GetFilledOrders returns the filled orders that were submitted after the given time.
+// If there is a problem getting the orders it returns nil and an error.
+func (client *AlpacaClient) GetFilledOrders(after time.Time) ([]alpaca.Order, error) {
+	orders, err := client.tradeClient.GetOrders(alpaca.GetOrdersRequest{
+		Status: "closed",
+		After:  after,
+		Limit:  500,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("get orders %w", err)
+	}
+
+	filled := make([]alpaca.Order, 0, len(orders))
+	for _, order := range orders {
+		if order.FilledAt != nil {
+			filled = append(filled, order)
+		}
+	}
+	return filled, nil
+}
+
 // TradeOrder returns an error if it was not able to send an order to the API.
 // It can make sorts, regular orders, stop loss orders, etc..., depending on the
-// context that is called.
-func (client *AlpacaClient) TradeOrder(symbol string, qty int64, side alpaca.Side) error {
-
-	if qty > 0 {
-		decimalQty := decimal.NewFromInt((qty))
-		order, err := client.tradeClient.PlaceOrder(alpaca.PlaceOrderRequest{
-			Symbol:      symbol,
-			Qty:         &decimalQty,
-			Side:        side,
-			Type:        "market",
-			TimeInForce: "day",
-		})
-		if err == nil {
-			fmt.Printf("Market order of | %d %s %s | completed\n", qty, symbol, side)
-			// Sleep to let the order fill.
-			time.Sleep(3 * time.Second)
-			err = client.stopLoss(order.ID)
-			if err != nil {
-				fmt.Println("Unable to set up a trailing stop order: %w", err)
-			}
-		} else {
-			fmt.Printf("Order of | %d %s %s | did not go through: %s\n", qty, symbol, side, err)
+// context that is called. If stop_distance is above 0 a stop loss is placed at that
+// fraction of the fill price, orders that close a position pass 0. The fractional mode
+// is only used if the asset is fractionable, otherwise the quantity is rounded down to
+// whole shares. The orders that close a position are market orders in the regular session.
+// It returns a SkipError if the quantity is 0, and an error if the order was not placed or
+// nothing was filled.
+func (client *AlpacaClient) TradeOrder(symbol string, qty decimal.Decimal, side alpaca.Side, stop_distance float64, fractional string) error {
+	// Outside the regular session only whole shares can be traded, with limit orders.
+	extended := client.inExtendedSession()
+	if extended {
+		fractional = risk.FractionalNone
+	}
+
+	qty, notional := client.orderSize(symbol, qty, side, fractional)
+	size := qty.String()
+	if notional != nil {
+		size = "$" + notional.String()
+	}
+	order_log := log.With().Str(logger.Symbol, symbol).Str("side", string(side)).Logger()
+
+	if !qty.IsPositive() && notional == nil {
+		return &SkipError{Symbol: symbol, Side: side, Reason: "quantity is <= 0"}
+	}
+
+	req := alpaca.PlaceOrderRequest{
+		Symbol:      symbol,
+		Side:        side,
+		Type:        alpaca.Market,
+		TimeInForce: alpaca.Day,
+	}
+	if notional != nil {
+		req.Notional = notional
+	} else {
+		req.Qty = &qty
+	}
+	req.ExtendedHours = extended
+
+	// Only the orders that open a position have a stop loss.
+	if err := client.guardOrder(&req, stop_distance > 0); err != nil {
+		return err
+	}
+	if req.Qty != nil {
+		qty, size = *req.Qty, req.Qty.String()
+	}
+	funded := client.checkCash(symbol, qty, req.Notional, side)
+	limit := extended || (client.execution != nil && client.execution.OrderType == initialize.OrderLimit && req.Type == alpaca.Market && stop_distance > 0)
+
+	if client.dryRun {
+		order_type := req.Type
+		if limit {
+			order_type = alpaca.Limit
 		}
+		order_log.Info().Str("type", string(order_type)).Str("size", size).Msg("[dry-run] would place the order")
+		if stop_distance <= 0 {
+			return nil
+		}
+		price, err := client.getLastQuote(symbol, side)
+		if err != nil {
+			order_log.Error().Err(err).Msg("unable to get the quote for the stop loss")
+			return nil
+		}
+		stop_price := stopLossPrice(decimal.NewFromFloat(price), side, stop_distance)
+		order_log.Info().Str("size", size).Str("stop_side", string(stopLossSide(side))).Str("stop_price", stop_price.String()).
+			Msg("[dry-run] would place the stop order")
 		return nil
 	}
-	fmt.Printf("Quantity is <= 0, order of | %d %s %s | not sent\n", qty, symbol, side)
+
+	// Every order is reported from its fill, the marketable limit orders made by guardOrder
+	// wait for it like the limit execution.
+	var filled, price decimal.Decimal
+	var err error
+	switch {
+	case limit:
+		filled, price, err = client.executeLimit(req)
+	case req.Type == alpaca.Limit:
+		filled, price, err = client.executeMarketable(req)
+	default:
+		filled, price, err = client.executeMarket(req)
+	}
+	if err != nil {
+		order_log.Error().Err(err).Str("type", string(req.Type)).Str("size", size).Msg("order did not go through")
+	}
+	if !filled.IsPositive() {
+		if err != nil {
+			return err
+		}
+		return fmt.Errorf("order for %s was not filled", symbol)
+	}
+
+	order_log.Info().Str("type", string(req.Type)).Str("filled", filled.String()).Str("price", price.Round(4).String()).Msg("order filled")
+	if client.onTrade != nil {
+		client.onTrade(symbol, filled, side, stop_distance > 0)
+	}
+	client.recordCash(symbol, filled, side, price.InexactFloat64(), funded)
+	if stop_distance > 0 && extended {
+		// The stop orders are not triggered outside the regular session, the bot watches it.
+		stop_price := stopLossPrice(price, side, stop_distance)
+		order_log.Info().Str("qty", filled.String()).Str("stop_side", string(stopLossSide(side))).Str("stop_price", stop_price.String()).
+			Msg("soft stop set")
+		if client.onSoftStop != nil {
+			client.onSoftStop(models.SoftStop{Symbol: symbol, Side: string(stopLossSide(side)), Price: stop_price.InexactFloat64()})
+		}
+	} else if stop_distance > 0 {
+		if err := client.placeStop(symbol, side, filled, price, stop_distance); err != nil {
+			order_log.Error().Err(err).Msg("unable to set up a stop order")
+		}
+	}
 	return nil
 }
 
+// placeOrder sends an order of TradeOrder. Its client order id starts with tradeOrderPrefix,
+// so its settlement is not recorded again by ReconcileCash.
+func (client *AlpacaClient) placeOrder(req alpaca.PlaceOrderRequest) (*alpaca.Order, error) {
+	req.ClientOrderID = fmt.Sprintf("%s%d", tradeOrderPrefix, time.Now().UnixNano())
+	return client.tradeClient.PlaceOrder(req)
+}
+
+// executeMarket sends the market order and waits up to marketFillWait for it to reach a
+// final status, what is still unfilled after that is cancelled. It returns the filled
+// quantity and its average price, which are zero if nothing was filled.
+func (client *AlpacaClient) executeMarket(req alpaca.PlaceOrderRequest) (decimal.Decimal, decimal.Decimal, error) {
+	order, err := client.placeOrder(req)
+	if err != nil {
+		return decimal.Zero, decimal.Zero, fmt.Errorf("place order: %w", err)
+	}
+	log.Debug().Str(logger.Symbol, req.Symbol).Str(logger.OrderID, order.ID).Str("side", string(req.Side)).Msg("market order placed")
+
+	deadline := time.Now().Add(marketFillWait)
+	for !finalStatuses[order.Status] && time.Now().Before(deadline) {
+		time.Sleep(flatPollInterval)
+		order, err = client.tradeClient.GetOrder(order.ID)
+		if err != nil {
+			return decimal.Zero, decimal.Zero, fmt.Errorf("get order: %w", err)
+		}
+	}
+	order, err = client.finishOrder(order.ID)
+	if err != nil {
+		return decimal.Zero, decimal.Zero, err
+	}
+	if !order.FilledQty.IsPositive() || order.FilledAvgPrice == nil {
+		return decimal.Zero, decimal.Zero, nil
+	}
+	return order.FilledQty, *order.FilledAvgPrice, nil
+}
+
+// orderSize returns the quantity of the order, or its notional value in dollars when it
+// should be sent as a notional order. Fractional quantities are only kept for fractionable
+// assets, and notional orders are only used for buys.
+func (client *AlpacaClient) orderSize(symbol string, qty decimal.Decimal, side alpaca.Side, fractional string) (decimal.Decimal, *decimal.Decimal) {
+	whole := qty.Floor()
+	if fractional == risk.FractionalNone || qty.Equal(whole) {
+		return whole, nil
+	}
+
+	asset, err := client.GetAsset(symbol)
+	if err != nil {
+		log.Warn().Err(err).Str(logger.Symbol, symbol).Msg("unable to check if the asset is fractionable, using whole shares")
+		return whole, nil
+	}
+	if !asset.Fractionable {
+		return whole, nil
+	}
+
+	if fractional == risk.FractionalNotional && side == alpaca.Buy {
+		price, err := client.getLastQuote(symbol, side)
+		if err == nil {
+			notional := qty.Mul(decimal.NewFromFloat(price)).Round(2)
+			return qty, &notional
+		}
+	}
+	return qty.Truncate(fractionalDecimals), nil
+}
+
 // IsMarketOpen returns true and nil if the market is currently open,
 // otherwise it returns false and nil. If there is a problem getting the time it returns
 // false and an error to go with it.
@@ -110,15 +340,15 @@ func (client *AlpacaClient) IsMarketOpen() (bool, error) {
 	timeToOpen := int(clock.NextOpen.Sub(clock.Timestamp).Minutes())
 	switch {
 	case timeToOpen < minutesThreshold:
-		fmt.Printf("%d minutes until next market open\n", timeToOpen)
+		log.Info().Msgf("%d minutes until next market open", timeToOpen)
 
 	case timeToOpen > minutesThreshold && timeToOpen < hoursThreshold:
 		hoursToOpen := timeToOpen / minutesThreshold
-		fmt.Printf("%d hours until next market open\n", hoursToOpen)
+		log.Info().Msgf("%d hours until next market open", hoursToOpen)
 
 	case timeToOpen > hoursThreshold:
 		daysToOpen := timeToOpen / hoursThreshold
-		fmt.Printf("%d days until next market open\n", daysToOpen)
+		log.Info().Msgf("%d days until next market open", daysToOpen)
 	}
 
 	return false, nil
@@ -130,6 +360,10 @@ func (client *AlpacaClient) IsMarketOpen() (bool, error) {
 // In any other case this function returns false. And in case of an error, this function returns
 // false and error.
 func (client *AlpacaClient) HaveTrades() (bool, error) {
+	// The day trade limit does not apply to cash accounts.
+	if client.cash != nil {
+		return true, nil
+	}
 	dayTradingCount, err := client.GetDayTradingCount()
 	if err != nil {
 		return false, fmt.Errorf("get day trading count %w", err)
@@ -140,7 +374,7 @@ func (client *AlpacaClient) HaveTrades() (bool, error) {
 	}
 
 	if dayTradingCount >= dayTradinglimit && equity < PDTEquity {
-		fmt.Println("warning: please do not make any more trades this week")
+		log.Warn().Int64("day_trades", dayTradingCount).Msg("please do not make any more trades this week")
 		return false, nil
 	}
 	return true, nil
@@ -176,6 +410,23 @@ func (client *AlpacaClient) GetEquity() (float64, error) {
 	return account.Equity.InexactFloat64(), nil
 }
 
+// GetUnrealizedPL returns the sum of the unrealized P&L of every open position,
+// if anything goes wrong it returns 0 and an error.
+func (client *AlpacaClient) GetUnrealizedPL() (float64, error) {
+	positions, err := client.tradeClient.GetPositions()
+	if err != nil {
+		return 0, fmt.Errorf("get positions %w", err)
+	}
+
+	unrealized := 0.0
+	for _, position := range positions {
+		if position.UnrealizedPL != nil {
+			unrealized += position.UnrealizedPL.InexactFloat64()
+		}
+	}
+	return unrealized, nil
+}
+
 // GetCash returns a float64 of the user's cash,
 // if anything goes wrong it returns 0 and an error.
 func (client *AlpacaClient) GetCash() (float64, error) {
@@ -196,142 +447,390 @@ func (client *AlpacaClient) GetDayTradingCount() (int64, error) {
 	return account.DaytradeCount, nil
 }
 
-// isBlocked returns true if the account is blocked in Alpaca
+// GetAccountNumber returns the number of the account, so it can be kept out of the logs.
+func (client *AlpacaClient) GetAccountNumber() (string, error) {
+	account, err := client.tradeClient.GetAccount()
+	if err != nil {
+		return "", fmt.Errorf("get account %w", err)
+	}
+	return account.AccountNumber, nil
+}
+
+// IsBlocked returns true if the account can't trade because of any restriction
 // otherwise it returns false. And it returns an error if an error is found.
 func (client *AlpacaClient) IsBlocked() (bool, error) {
+	restriction, err := client.GetRestriction()
+	if err != nil {
+		return true, err
+	}
+	return restriction != "", nil
+}
+
+// GetRestriction returns the reason why the account can't trade, or an empty string if it
+// can. The account is restricted if it is blocked, its trading is blocked or suspended by
+// the user, or its status is not active.
+func (client *AlpacaClient) GetRestriction() (string, error) {
 	account, err := client.tradeClient.GetAccount()
 	if err != nil {
-		return true, fmt.Errorf("get account %w", err)
+		return "", fmt.Errorf("get account %w", err)
 	}
-	return account.AccountBlocked, nil
+
+	switch {
+	case account.AccountBlocked:
+		return "the account is blocked", nil
+	case account.TradingBlocked:
+		return "the trading of the account is blocked", nil
+	case account.TradeSuspendedByUser:
+		return "the trading was suspended by the user", nil
+	case account.Status != accountActive:
+		return fmt.Sprintf("the account status is %s", account.Status), nil
+	}
+	return "", nil
 }
 
 // getLastQuote returns the latest active quote of a stock( if you have unlimited subscription
 // please change it to marketdata.SIP). If it is unable to get the latest quote it returns a
 // default price of 20.
 func (client *AlpacaClient) getLastQuote(symbol string, side alpaca.Side) (float64, error) {
-	req := marketdata.GetSnapshotRequest{
+	quote, err := client.GetSnapshotQuote(symbol)
+	if err != nil {
+		return stockDefaultPrice, err
+	}
+	if side == alpaca.Buy {
+		return quote.Ask, nil
+	}
+	return quote.Bid, nil
+}
+
+// GetQuote returns the latest ask price of a stock for a buy and the latest bid price
+// for a sell. If it is unable to get the quote it returns the default price and an error.
+func (client *AlpacaClient) GetQuote(symbol string, side alpaca.Side) (float64, error) {
+	return client.getLastQuote(symbol, side)
+}
+
+// GetPrice returns the price of the latest trade of a stock. If it is unable to
+// get the latest trade it returns 0 and an error.
+func (client *AlpacaClient) GetPrice(symbol string) (float64, error) {
+	trade, err := client.dataClient.GetLatestTrade(symbol, marketdata.GetLatestTradeRequest{
 		Feed:     marketdata.IEX,
 		Currency: "USD",
+	})
+	if err != nil {
+		return 0, fmt.Errorf("get latest trade: %w", err)
 	}
+	return trade.Price, nil
+}
 
-	gmeSnapshot, err := client.dataClient.GetSnapshot(symbol, req)
+// ShortPosition is a function that takes care of every variable and property regarding
+// a short. It returns nil if a short was sucessfully placed, a SkipError if the short did
+// not pass the pre-trade checks, and an error otherwise.
+func (client *AlpacaClient) ShortPosition(response int, symbol string, profile risk.Profile) error {
+	if client.cash != nil {
+		return &SkipError{Symbol: symbol, Side: alpaca.Sell, Rule: "cash", Reason: "cash accounts can't short"}
+	}
+
+	buyingPower, err := client.getBuyingPower()
 	if err != nil {
-		return stockDefaultPrice, fmt.Errorf("get snapshot: %w", err)
+		return fmt.Errorf("unable to get account: %w", err)
+	}
+	if buyingPower < minimalShortingBuyingPower {
+		return &SkipError{Symbol: symbol, Side: alpaca.Sell, Reason: fmt.Sprintf("buying power below %.0f", minimalShortingBuyingPower)}
 	}
 
-	if gmeSnapshot == nil || gmeSnapshot.LatestQuote == nil {
-		return stockDefaultPrice, fmt.Errorf("snapshot or latest quote is nil")
+	if _, err := client.tradeClient.GetPosition(symbol); err != nil {
+		can_open, err := client.canOpenPosition(profile)
+		if err != nil {
+			return fmt.Errorf("unable to count positions %w", err)
+		}
+		if !can_open {
+			return &SkipError{Symbol: symbol, Side: alpaca.Sell, Reason: fmt.Sprintf("max positions of the %s profile reached", profile.Name)}
+		}
 	}
-	if side == alpaca.Buy {
-		return gmeSnapshot.LatestQuote.AskPrice, nil
+
+	if _, err := client.checkAsset(symbol, alpaca.Sell, true, risk.FractionalNone); err != nil {
+		return err
 	}
-	return gmeSnapshot.LatestQuote.BidPrice, nil
-}
 
-// SellPosition is a function that takes care of every variable and property regarding
-// a sell or a short. It returns nil if a short or a sell was sucessfully placed, and an error
-// otherwise.
-func (client *AlpacaClient) SellPosition(symbol string, response int, risk enums.Risk) error {
-	buyingPower, err := client.getBuyingPower()
+	qty, stop_distance, err := client.Size(response, symbol, alpaca.Sell, profile)
 	if err != nil {
-		return fmt.Errorf("unable to get account: %w", err)
+		return fmt.Errorf("unable to get quantity %w", err)
 	}
 
-	position, err := client.tradeClient.GetPosition(symbol)
-	if err != nil && buyingPower >= minimalShortingBuyingPower {
+	qty = client.extendedSize(symbol, qty, alpaca.Sell)
+	if err := client.checkCompliance(symbol, qty, alpaca.Sell); err != nil {
+		return err
+	}
+
+	// Fractional shares can't be shorted.
+	if err := client.TradeOrder(symbol, qty, alpaca.Sell, stop_distance, risk.FractionalNone); err != nil {
+		return fmt.Errorf("error placing order %w", err)
+	}
+	return nil
+}
 
-		qty, err := client.GetQuantity(response, symbol, alpaca.Sell, risk)
+// ClosePosition cancels the open orders of the symbol, its stop loss included, and
+// closes the position with a market order. It returns nil if there is no position.
+func (client *AlpacaClient) ClosePosition(symbol string) error {
+	qty, err := client.GetPositionQty(symbol)
+	if err != nil {
+		return err
+	}
+	if qty.IsZero() {
+		return nil
+	}
 
+	if client.dryRun {
+		log.Info().Str(logger.Symbol, symbol).Msg("[dry-run] would cancel the open orders")
+	} else {
+		orders, err := client.tradeClient.GetOrders(alpaca.GetOrdersRequest{
+			Status:  "open",
+			Symbols: []string{symbol},
+		})
 		if err != nil {
-			return fmt.Errorf("unable to get quantity %w", err)
+			return fmt.Errorf("get open orders: %w", err)
 		}
+		for _, order := range orders {
+			if err := client.tradeClient.CancelOrder(order.ID); err != nil {
+				return fmt.Errorf("cancel order %s: %w", order.ID, err)
+			}
+		}
+	}
+
+	side := alpaca.Sell
+	if qty.IsNegative() {
+		side = alpaca.Buy
+	}
+	if err := client.TradeOrder(symbol, qty.Abs(), side, 0, risk.FractionalQty); err != nil {
+		return fmt.Errorf("error placing order %w", err)
+	}
+	return nil
+}
 
-		client.TradeOrder(symbol, qty, alpaca.Sell)
+// WaitFlat polls the position of the symbol until it is closed. It returns an error if
+// the position is still open after the timeout. In dry-run nothing was closed, so it
+// returns right away.
+func (client *AlpacaClient) WaitFlat(symbol string, timeout time.Duration) error {
+	if client.dryRun {
 		return nil
 	}
+	deadline := time.Now().Add(timeout)
+	for {
+		qty, err := client.GetPositionQty(symbol)
+		if err != nil {
+			return err
+		}
+		if qty.IsZero() {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("the position of %s is still %s after %s", symbol, qty, timeout)
+		}
+		time.Sleep(flatPollInterval)
+	}
+}
 
-	if position.QtyAvailable.IntPart() > 0 {
-		qty := position.Qty.Abs()
+// GetHoldings returns the open positions.
+func (client *AlpacaClient) GetHoldings() ([]models.Holding, error) {
+	positions, err := client.tradeClient.GetPositions()
+	if err != nil {
+		return nil, fmt.Errorf("get positions: %w", err)
+	}
 
-		err := client.TradeOrder(symbol, qty.IntPart(), alpaca.Sell)
-		if err != nil {
-			return fmt.Errorf("error placing order %w", err)
+	value := func(d *decimal.Decimal) float64 {
+		if d == nil {
+			return 0
 		}
-		return nil
+		return d.InexactFloat64()
+	}
+	holdings := make([]models.Holding, 0, len(positions))
+	for _, position := range positions {
+		holdings = append(holdings, models.Holding{
+			Symbol:              position.Symbol,
+			Qty:                 position.Qty.InexactFloat64(),
+			AvgEntryPrice:       position.AvgEntryPrice.InexactFloat64(),
+			CurrentPrice:        value(position.CurrentPrice),
+			MarketValue:         value(position.MarketValue),
+			UnrealizedPL:        value(position.UnrealizedPL),
+			UnrealizedPLPercent: value(position.UnrealizedPLPC),
+		})
 	}
-	return nil
+	return holdings, nil
+}
+
+// GetPositionQty returns the quantity held of the symbol, negative for a short.
+// It returns zero if there is no position.
+func (client *AlpacaClient) GetPositionQty(symbol string) (decimal.Decimal, error) {
+	positions, err := client.tradeClient.GetPositions()
+	if err != nil {
+		return decimal.Zero, fmt.Errorf("get positions: %w", err)
+	}
+	for _, position := range positions {
+		if position.Symbol == symbol {
+			return position.Qty, nil
+		}
+	}
+	return decimal.Zero, nil
 }
 
 // BuyPosition is a function that takes care of every variable and property regarding
-// a buy. It returns nil if a buywas sucessfully placed, and an error
-// otherwise.
-func (client *AlpacaClient) BuyPosition(response int, symbol string, risk enums.Risk) error {
-	buy_quantity, err := client.GetQuantity(response, symbol, alpaca.Buy, risk)
+// a buy. It returns nil if a buywas sucessfully placed, a SkipError if the buy did not
+// pass the pre-trade checks, and an error otherwise.
+func (client *AlpacaClient) BuyPosition(response int, symbol string, profile risk.Profile) error {
+	if _, err := client.tradeClient.GetPosition(symbol); err != nil {
+		can_open, err := client.canOpenPosition(profile)
+		if err != nil {
+			return fmt.Errorf("unable to count positions %w", err)
+		}
+		if !can_open {
+			return &SkipError{Symbol: symbol, Side: alpaca.Buy, Reason: fmt.Sprintf("max positions of the %s profile reached", profile.Name)}
+		}
+	}
+
+	fractional, err := client.checkAsset(symbol, alpaca.Buy, false, profile.Fractional)
+	if err != nil {
+		return err
+	}
+
+	buy_quantity, stop_distance, err := client.Size(response, symbol, alpaca.Buy, profile)
 	if err != nil {
 		return fmt.Errorf("error setting buy quantity error ")
 	}
-	if client.TradeOrder(symbol, buy_quantity, alpaca.Buy) != nil {
+	buy_quantity = client.extendedSize(symbol, buy_quantity, alpaca.Buy)
+	if err := client.checkCompliance(symbol, buy_quantity, alpaca.Buy); err != nil {
+		return err
+	}
+	if err := client.TradeOrder(symbol, buy_quantity, alpaca.Buy, stop_distance, fractional); err != nil {
 		return fmt.Errorf("error making the trade: %w", err)
 	}
 	return nil
 }
 
-// GetQuantity returns the quantity in int64 of the stock to sell or buy.
-// The quantity varies according to the action(side), the risk selected and the sentiment analysis.
+// GetQuantity returns the quantity of the stock to sell or buy, it can be fractional.
+// The quantity varies according to the action(side), the risk profile selected and the sentiment analysis.
 // If there is a problem getting the quote or the buying power it will return 0 and an error.
-func (client *AlpacaClient) GetQuantity(response int, symbol string, side alpaca.Side, risk enums.Risk) (int64, error) {
+func (client *AlpacaClient) GetQuantity(response int, symbol string, side alpaca.Side, profile risk.Profile) (decimal.Decimal, error) {
+	qty, _, err := client.Size(response, symbol, side, profile)
+	return qty, err
+}
+
+// Size returns the quantity of the stock to sell or buy and the distance of
+// its stop loss, as a fraction of the price. With the volatility sizing the stop is
+// placed according to the recent volatility of the stock, otherwise at the stop distance
+// of the profile. If there is a problem getting the buying power it will return 0 and an error.
+func (client *AlpacaClient) Size(response int, symbol string, side alpaca.Side, profile risk.Profile) (decimal.Decimal, float64, error) {
 	buyingPower, err := client.getBuyingPower()
 	if err != nil {
-		return 0, fmt.Errorf("error getting buying power: %w", err)
+		return decimal.Zero, 0, fmt.Errorf("error getting buying power: %w", err)
+	}
+	// A cash account only trades with settled funds.
+	if client.cash != nil {
+		buyingPower, _, err = client.GetSettledCash()
+		if err != nil {
+			return decimal.Zero, 0, fmt.Errorf("error getting settled cash: %w", err)
+		}
+		buyingPower = max(buyingPower, 0)
+	}
+	if power, ok, err := client.dayTradingPower(profile); err != nil {
+		return decimal.Zero, 0, fmt.Errorf("error getting day trading buying power: %w", err)
+	} else if ok {
+		buyingPower = power
 	}
 
 	latestQuote, err := client.getLastQuote(symbol, side)
 	if err != nil {
-		fmt.Println("error getting last quote: %w", err)
+		log.Error().Err(err).Str(logger.Symbol, symbol).Msg("error getting last quote")
 	}
 
 	if latestQuote == 0.0 {
 		latestQuote = 1.0
 	}
 
-	if risk == enums.Medium || risk == enums.Low || risk == enums.High {
-		if side == alpaca.Buy {
-			return utils.BuyPDTQuantity(int64(response), buyingPower, latestQuote, risk), nil
-		} else {
-			return utils.SellPDTQuantity(int64(response), buyingPower, latestQuote, risk), nil
+	if profile.Sizing == risk.SizingVolatility {
+		equity, err := client.GetEquity()
+		if err != nil {
+			return decimal.Zero, 0, fmt.Errorf("error getting equity: %w", err)
+		}
+
+		stop, err := client.volatilityStop(symbol, latestQuote, profile.Volatility)
+		if err != nil {
+			log.Warn().Err(err).Str(logger.Symbol, symbol).Msg("unable to measure the volatility, using the stop distance")
+			stop = latestQuote * profile.StopDistance
 		}
-	} else if risk == enums.Safe || risk == enums.Power {
-		return utils.CalculateQuantity(buyingPower, latestQuote, risk), nil
+
+		qty := utils.VolatilityQuantity(equity, profile.Volatility.RiskPerTrade, stop, buyingPower, latestQuote)
+		return decimal.NewFromFloat(qty), stop / latestQuote, nil
 	}
-	return 0, nil
-}
 
-// stopLoss returns an error if a stop loss was not sucessfully set up.
-// The stop loss currently is set at 10% of the original value.
-// If everything goes well it returns nil.
-func (client *AlpacaClient) stopLoss(orderId string) error {
+	if side == alpaca.Buy {
+		return decimal.NewFromFloat(utils.BuyQuantity(profile, response, buyingPower, latestQuote)), profile.StopDistance, nil
+	}
+	return decimal.NewFromFloat(utils.SellQuantity(profile, response, buyingPower, latestQuote)), profile.StopDistance, nil
+}
 
-	fmt.Printf("orderId %s", orderId)
-	order, err := client.tradeClient.GetOrder(orderId)
+// volatilityStop returns the distance in dollars between the entry and the stop loss,
+// measured from the daily bars of the stock. It returns an error if there are not
+// enough bars or the stop would be beyond the price.
+func (client *AlpacaClient) volatilityStop(symbol string, latestQuote float64, volatility risk.Volatility) (float64, error) {
+	bars, err := client.dataClient.GetBars(symbol, marketdata.GetBarsRequest{
+		TimeFrame:  marketdata.OneDay,
+		Adjustment: marketdata.Split,
+		Start:      time.Now().AddDate(0, 0, -3*volatility.Period),
+		Feed:       marketdata.IEX,
+	})
 	if err != nil {
-		return fmt.Errorf("order has not been filled, %w", err)
+		return 0, fmt.Errorf("get bars: %w", err)
 	}
-	stopLossSide := alpaca.Buy
-	if order.Side == alpaca.Buy {
-		stopLossSide = alpaca.Sell
-	} else if order.Side == alpaca.Sell {
-		stopLossSide = alpaca.Buy
+
+	highs := make([]float64, len(bars))
+	lows := make([]float64, len(bars))
+	closes := make([]float64, len(bars))
+	for i, bar := range bars {
+		highs[i], lows[i], closes[i] = bar.High, bar.Low, bar.Close
 	}
 
-	if order.FilledAvgPrice == nil {
-		return fmt.Errorf("FilledAvgPrice is nil")
+	var range_dollars float64
+	if volatility.Method == risk.VolatilityRealized {
+		range_dollars = utils.RealizedVolatility(closes, volatility.Period) * latestQuote
+	} else {
+		range_dollars = utils.ATR(highs, lows, closes, volatility.Period)
 	}
-	stop_price := order.FilledAvgPrice.Mul(decimal.NewFromFloat(0.90).Abs()).Round(2)
-	_, err = client.tradeClient.PlaceOrder(alpaca.PlaceOrderRequest{
-		Symbol:      order.Symbol,
-		Qty:         order.Qty,
-		Side:        stopLossSide,
+
+	stop := range_dollars * volatility.Multiple
+	if stop <= 0 {
+		return 0, fmt.Errorf("not enough bars for %s, got %d", symbol, len(bars))
+	}
+	if stop >= latestQuote {
+		return 0, fmt.Errorf("stop of %.2f is beyond the price of %s", stop, symbol)
+	}
+	return stop, nil
+}
+
+// canOpenPosition returns true if the profile allows another open position.
+// A profile without max positions never limits them.
+func (client *AlpacaClient) canOpenPosition(profile risk.Profile) (bool, error) {
+	max_positions := profile.MaxPositions
+	if client.ExtendedHours() && client.extended.MaxPositions > 0 && client.inExtendedSession() {
+		if max_positions <= 0 || client.extended.MaxPositions < max_positions {
+			max_positions = client.extended.MaxPositions
+		}
+	}
+	if max_positions <= 0 {
+		return true, nil
+	}
+	positions, err := client.tradeClient.GetPositions()
+	if err != nil {
+		return false, fmt.Errorf("get positions %w", err)
+	}
+	return len(positions) < max_positions, nil
+}
+
+// placeStop places the stop loss of a fill of the given side, quantity and price.
+func (client *AlpacaClient) placeStop(symbol string, side alpaca.Side, qty decimal.Decimal, price decimal.Decimal, stop_distance float64) error {
+	stop_price := stopLossPrice(price, side, stop_distance)
+	order, err := client.tradeClient.PlaceOrder(alpaca.PlaceOrderRequest{
+		Symbol:      symbol,
+		Qty:         &qty,
+		Side:        stopLossSide(side),
 		Type:        "stop",
 		StopPrice:   &stop_price,
 		TimeInForce: "day",
@@ -339,27 +838,51 @@ func (client *AlpacaClient) stopLoss(orderId string) error {
 	if err != nil {
 		return fmt.Errorf("unable to set a stop loss: %w", err)
 	}
-	fmt.Println("stop loss order set")
+	log.Info().Str(logger.Symbol, symbol).Str(logger.OrderID, order.ID).Str("stop_price", stop_price.String()).Msg("stop loss order set")
 	return nil
 }
 
-// CanClosePositions returns true if there are 15 minutes left on the market hours
-// and closes the positions. If there are more than 15 min it returns false.
+// stopLossSide returns the side of the stop loss that protects an order of the given side.
+func stopLossSide(side alpaca.Side) alpaca.Side {
+	if side == alpaca.Buy {
+		return alpaca.Sell
+	}
+	return alpaca.Buy
+}
+
+// stopLossPrice returns the price of the stop loss given the fill price and side of the
+// order. The stop of a buy is below the fill price and the stop of a short above it.
+func stopLossPrice(price decimal.Decimal, side alpaca.Side, stop_distance float64) decimal.Decimal {
+	if side == alpaca.Buy {
+		return price.Mul(decimal.NewFromFloat(1 - stop_distance)).Round(2)
+	}
+	return price.Mul(decimal.NewFromFloat(1 + stop_distance)).Round(2)
+}
+
+// CanClosePositions returns true if there are 15 minutes left on the market hours, or on
+// the after-hours when the extended hours trading is enabled, and closes the positions.
+// If there are more than 15 min it returns false.
 // If there is a problem getting any data it returns false and an error.
 func (client *AlpacaClient) CanClosePositions() (bool, error) {
-	clock, err := client.tradeClient.GetClock()
+	closing, err := client.IsClosing()
+	if err != nil || !closing {
+		return false, err
+	}
+	log.Info().Msg("15 minutes left until the end of the session, closing all positions")
+	err = client.ClosePositions()
 	if err != nil {
-		return false, fmt.Errorf("get clock: %w", err)
+		return true, err
 	}
-	nextClose := clock.NextClose
-	closeTime := nextClose.Add(-15 * time.Minute)
-	if clock.IsOpen && time.Now().After(closeTime) {
-		fmt.Println("15 minutes left until market close. \n Closing all positions.")
-		err := client.ClosePositions()
-		if err != nil {
-			return true, err
-		}
-		return true, nil
+	return true, nil
+}
+
+// IsClosing returns true if there are 15 minutes left on the market hours, or on
+// the after-hours when the extended hours trading is enabled.
+func (client *AlpacaClient) IsClosing() (bool, error) {
+	end, err := client.GetSessionEnd()
+	if err != nil || end.IsZero() {
+		return false, err
 	}
-	return false, nil
+	closeTime := end.Add(-15 * time.Minute)
+	return time.Now().After(closeTime) && time.Now().Before(end), nil
 }
@@ -0,0 +1,101 @@
+// Package alpaca provides auxiliary functions to connect with
+// the Alpaca API.
+package alpaca
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/alpacahq/alpaca-trade-api-go/v3/alpaca"
+	"github.com/jmvdr-iscte/TradingBotCli/logger"
+	"github.com/jmvdr-iscte/TradingBotCli/risk"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	assetCacheTTL = time.Hour
+	otcExchange   = "OTC"
+)
+
+// SkipError is returned when a trade is not sent because of a pre-trade check.
+type SkipError struct {
+	Symbol string
+	Side   alpaca.Side
+	Rule   string
+	Reason string
+}
+
+// Error returns the reason why the trade was skipped.
+func (e *SkipError) Error() string {
+	if e.Rule != "" {
+		return fmt.Sprintf("%s of %s skipped by rule %s: %s", e.Side, e.Symbol, e.Rule, e.Reason)
+	}
+	return fmt.Sprintf("%s of %s skipped: %s", e.Side, e.Symbol, e.Reason)
+}
+
+// assetCache keeps the asset metadata so every trade does not hit the assets endpoint.
+type assetCache struct {
+	mu      sync.Mutex
+	entries map[string]cachedAsset
+}
+
+type cachedAsset struct {
+	asset     *alpaca.Asset
+	fetchedAt time.Time
+}
+
+func newAssetCache() *assetCache {
+	return &assetCache{
+		entries: make(map[string]cachedAsset),
+	}
+}
+
+// GetAsset returns the metadata of the asset, cached for an hour.
+// If it is unable to get the asset it returns nil and an error.
+func (client *AlpacaClient) GetAsset(symbol string) (*alpaca.Asset, error) {
+	client.assets.mu.Lock()
+	entry, exists := client.assets.entries[symbol]
+	client.assets.mu.Unlock()
+	if exists && time.Since(entry.fetchedAt) < assetCacheTTL {
+		return entry.asset, nil
+	}
+
+	asset, err := client.tradeClient.GetAsset(symbol)
+	if err != nil {
+		return nil, fmt.Errorf("get asset: %w", err)
+	}
+
+	client.assets.mu.Lock()
+	client.assets.entries[symbol] = cachedAsset{asset: asset, fetchedAt: time.Now()}
+	client.assets.mu.Unlock()
+	return asset, nil
+}
+
+// checkAsset returns a SkipError if the asset can't be traded on the given side.
+// Shorts also need the asset to be shortable and easy to borrow. It returns the fractional
+// mode of the order, downgraded to whole shares when the asset is not fractionable.
+func (client *AlpacaClient) checkAsset(symbol string, side alpaca.Side, short bool, fractional string) (string, error) {
+	asset, err := client.GetAsset(symbol)
+	if err != nil {
+		return fractional, &SkipError{Symbol: symbol, Side: side, Reason: fmt.Sprintf("unable to check the asset: %s", err)}
+	}
+
+	switch {
+	case asset.Status != alpaca.AssetActive || !asset.Tradable:
+		return fractional, &SkipError{Symbol: symbol, Side: side, Reason: "asset is not tradable"}
+	case asset.Exchange == otcExchange:
+		return fractional, &SkipError{Symbol: symbol, Side: side, Reason: "asset is an OTC listing"}
+	case short && !asset.Shortable:
+		return fractional, &SkipError{Symbol: symbol, Side: side, Reason: "asset is not shortable"}
+	case short && !asset.EasyToBorrow:
+		return fractional, &SkipError{Symbol: symbol, Side: side, Reason: "asset is hard to borrow"}
+	}
+
+	if fractional != risk.FractionalNone && !asset.Fractionable {
+		log.Warn().Str(logger.Symbol, symbol).Str("side", string(side)).Str("rule", "asset").Str("fractional", fractional).
+			Msg("asset is not fractionable, downgraded to whole shares")
+		return risk.FractionalNone, nil
+	}
+	return fractional, nil
+}
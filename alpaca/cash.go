@@ -0,0 +1,178 @@
+// Package alpaca provides auxiliary functions to connect with
+// the Alpaca API.
+package alpaca
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/alpacahq/alpaca-trade-api-go/v3/alpaca"
+	"github.com/jmvdr-iscte/TradingBotCli/cash"
+	"github.com/jmvdr-iscte/TradingBotCli/logger"
+	"github.com/jmvdr-iscte/TradingBotCli/session"
+	"github.com/rs/zerolog/log"
+	"github.com/shopspring/decimal"
+)
+
+// SetCashAccount makes the client trade as a cash account, it never shorts and only
+// sizes the orders from the settled cash recorded in the ledger.
+func (client *AlpacaClient) SetCashAccount(ledger *cash.Ledger) {
+	client.cash = ledger
+}
+
+// CashAccount returns true if the client trades as a cash account.
+func (client *AlpacaClient) CashAccount() bool {
+	return client.cash != nil
+}
+
+// GetSettledCash returns the cash without the proceeds of the sales that did not settle yet,
+// and the time the last of them settles. If anything goes wrong it returns 0 and an error.
+func (client *AlpacaClient) GetSettledCash() (float64, time.Time, error) {
+	cash, err := client.GetCash()
+	if err != nil {
+		return 0, time.Time{}, err
+	}
+	unsettled, last, err := client.cash.Unsettled(context.Background(), time.Now())
+	if err != nil {
+		return 0, time.Time{}, err
+	}
+	return cash - unsettled, last, nil
+}
+
+// GetSettlementDate returns the time the trades made today settle, the start of the
+// next business day of the market calendar.
+func (client *AlpacaClient) GetSettlementDate() (time.Time, error) {
+	location, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		return time.Time{}, fmt.Errorf("load market location: %w", err)
+	}
+
+	today := time.Now().In(location)
+	days, err := client.tradeClient.GetCalendar(alpaca.GetCalendarRequest{
+		Start: today.AddDate(0, 0, 1),
+		End:   today.AddDate(0, 0, 10),
+	})
+	if err != nil {
+		return time.Time{}, fmt.Errorf("get calendar: %w", err)
+	}
+	if len(days) == 0 {
+		return today.AddDate(0, 0, 1), nil
+	}
+
+	settles, err := time.ParseInLocation(time.DateOnly, days[0].Date, location)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("parse calendar date: %w", err)
+	}
+	return settles, nil
+}
+
+// checkCash warns before an order that would cause a good-faith violation. For a buy it
+// returns the time the funds used settle if it is not covered by the settled cash, and
+// the zero time otherwise.
+func (client *AlpacaClient) checkCash(symbol string, qty decimal.Decimal, notional *decimal.Decimal, side alpaca.Side) time.Time {
+	if client.cash == nil {
+		return time.Time{}
+	}
+
+	if side == alpaca.Sell {
+		violation, err := client.cash.GoodFaithViolation(context.Background(), symbol, time.Now())
+		if err != nil {
+			log.Error().Err(err).Str(logger.Symbol, symbol).Msg("unable to check the settlement")
+		} else if violation {
+			log.Warn().Str(logger.Symbol, symbol).Msg("bought with unsettled funds, selling it now is a good-faith violation")
+		}
+		return time.Time{}
+	}
+
+	value := notional
+	if value == nil {
+		price, err := client.getLastQuote(symbol, side)
+		if err != nil {
+			log.Error().Err(err).Str(logger.Symbol, symbol).Msg("unable to price the buy")
+			return time.Time{}
+		}
+		cost := qty.Mul(decimal.NewFromFloat(price))
+		value = &cost
+	}
+
+	settled, last, err := client.GetSettledCash()
+	if err != nil {
+		log.Error().Err(err).Msg("unable to get the settled cash")
+		return time.Time{}
+	}
+	if value.InexactFloat64() <= settled {
+		return time.Time{}
+	}
+	log.Warn().Str(logger.Symbol, symbol).Str("settles", last.Format(time.DateOnly)).
+		Msg("buying with unsettled funds, selling it before they settle is a good-faith violation")
+	return last
+}
+
+// recordCash records the settlement of a filled order. The proceeds of a sale settle the
+// next business day, and a buy made with unsettled funds is kept until they settle.
+func (client *AlpacaClient) recordCash(symbol string, qty decimal.Decimal, side alpaca.Side, price float64, funded time.Time) {
+	if client.cash == nil {
+		return
+	}
+	ctx := context.Background()
+
+	if side == alpaca.Buy {
+		if funded.IsZero() {
+			return
+		}
+		if err := client.cash.Buy(ctx, symbol, funded); err != nil {
+			log.Error().Err(err).Str(logger.Symbol, symbol).Msg("unable to record the funding of the buy")
+		}
+		return
+	}
+
+	settles, err := client.GetSettlementDate()
+	if err != nil {
+		log.Warn().Err(err).Msg("unable to get the settlement date, using the next day")
+		settles = time.Now().AddDate(0, 0, 1)
+	}
+	if err := client.cash.AddProceeds(ctx, symbol, qty.InexactFloat64()*price, settles); err != nil {
+		log.Error().Err(err).Str(logger.Symbol, symbol).Msg("unable to record the proceeds of the sale")
+	}
+	if err := client.cash.Sold(ctx, symbol); err != nil {
+		log.Error().Err(err).Str(logger.Symbol, symbol).Msg("unable to delete the funding of the sale")
+	}
+}
+
+// ReconcileCash records the proceeds of the sales filled today that were not sent by
+// TradeOrder, like the stops triggered at the broker, once per order. The orders are
+// looked up a week back, since the GTC stops are placed before the day they fill.
+func (client *AlpacaClient) ReconcileCash() error {
+	if client.cash == nil || client.dryRun {
+		return nil
+	}
+	ctx := context.Background()
+	now := time.Now()
+	today := session.TradingDate(now)
+
+	orders, err := client.GetFilledOrders(now.Add(-cashLookback))
+	if err != nil {
+		return err
+	}
+	for _, order := range orders {
+		if order.Side != alpaca.Sell || order.FilledAvgPrice == nil || !order.FilledQty.IsPositive() {
+			continue
+		}
+		if strings.HasPrefix(order.ClientOrderID, tradeOrderPrefix) || session.TradingDate(*order.FilledAt) != today {
+			continue
+		}
+		recorded, err := client.cash.Record(ctx, order.ID)
+		if err != nil {
+			return err
+		}
+		if !recorded {
+			continue
+		}
+		log.Info().Str(logger.Symbol, order.Symbol).Str(logger.OrderID, order.ID).Str("qty", order.FilledQty.String()).
+			Msg("sale filled at the broker, recording its proceeds")
+		client.recordCash(order.Symbol, order.FilledQty, alpaca.Sell, order.FilledAvgPrice.InexactFloat64(), time.Time{})
+	}
+	return nil
+}
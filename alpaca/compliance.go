@@ -0,0 +1,120 @@
+// Package alpaca provides auxiliary functions to connect with
+// the Alpaca API.
+package alpaca
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/alpacahq/alpaca-trade-api-go/v3/alpaca"
+	"github.com/jmvdr-iscte/TradingBotCli/compliance"
+	"github.com/shopspring/decimal"
+)
+
+// SetCompliance sets the rule engine every opening order is checked against.
+// The lastTrade function returns the time of the latest trade of a symbol.
+func (client *AlpacaClient) SetCompliance(engine *compliance.Engine, lastTrade func(symbol string) (time.Time, error)) {
+	client.compliance = engine
+	client.lastTrade = lastTrade
+}
+
+// checkCompliance returns a SkipError with the broken rule if the order does not
+// pass the compliance rules.
+func (client *AlpacaClient) checkCompliance(symbol string, qty decimal.Decimal, side alpaca.Side) error {
+	if client.compliance == nil {
+		return nil
+	}
+
+	state, price, err := client.complianceState(symbol, side)
+	if err != nil {
+		return &SkipError{Symbol: symbol, Side: side, Reason: fmt.Sprintf("unable to check the compliance rules: %s", err)}
+	}
+
+	order := compliance.Order{
+		Symbol: symbol,
+		Buy:    side == alpaca.Buy,
+		Qty:    qty.InexactFloat64(),
+		Price:  price,
+		Time:   time.Now(),
+	}
+	if err := client.compliance.Check(order, state); err != nil {
+		if rejection, ok := err.(*compliance.Rejection); ok {
+			return &SkipError{Symbol: symbol, Side: side, Rule: rejection.Rule, Reason: rejection.Reason}
+		}
+		return &SkipError{Symbol: symbol, Side: side, Reason: err.Error()}
+	}
+	return nil
+}
+
+// complianceState returns the state of the account and the market the rules are
+// checked against, and the price the order would be filled at.
+func (client *AlpacaClient) complianceState(symbol string, side alpaca.Side) (compliance.State, float64, error) {
+	var state compliance.State
+
+	holdings, err := client.GetHoldings()
+	if err != nil {
+		return state, 0, err
+	}
+	for _, holding := range holdings {
+		state.Positions = append(state.Positions, compliance.Position{
+			Symbol:      holding.Symbol,
+			Qty:         holding.Qty,
+			MarketValue: holding.MarketValue,
+		})
+	}
+
+	quote, err := client.GetSnapshotQuote(symbol)
+	if err != nil {
+		return state, 0, err
+	}
+	state.Bid = quote.Bid
+	state.Ask = quote.Ask
+	price := state.Bid
+	if side == alpaca.Buy {
+		price = state.Ask
+	}
+
+	state.MarketOpen, state.MarketClose, err = client.GetSessionHours()
+	if err != nil {
+		return state, 0, err
+	}
+
+	if client.lastTrade != nil {
+		state.LastTrade, err = client.lastTrade(symbol)
+		if err != nil {
+			return state, 0, err
+		}
+	}
+	return state, price, nil
+}
+
+// GetSessionHours returns the open and the close of today's regular session.
+// It returns zero times if the market does not open today.
+func (client *AlpacaClient) GetSessionHours() (time.Time, time.Time, error) {
+	location, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("load market location: %w", err)
+	}
+
+	today := time.Now().In(location)
+	days, err := client.tradeClient.GetCalendar(alpaca.GetCalendarRequest{
+		Start: today,
+		End:   today,
+	})
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("get calendar: %w", err)
+	}
+	if len(days) == 0 || days[0].Date != today.Format(time.DateOnly) {
+		return time.Time{}, time.Time{}, nil
+	}
+
+	open, err := time.ParseInLocation("2006-01-02 15:04", days[0].Date+" "+days[0].Open, location)
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("parse open: %w", err)
+	}
+	closing, err := time.ParseInLocation("2006-01-02 15:04", days[0].Date+" "+days[0].Close, location)
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("parse close: %w", err)
+	}
+	return open, closing, nil
+}
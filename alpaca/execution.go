@@ -0,0 +1,111 @@
+// Package alpaca provides auxiliary functions to connect with
+// the Alpaca API.
+package alpaca
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/alpacahq/alpaca-trade-api-go/v3/alpaca"
+	"github.com/alpacahq/alpaca-trade-api-go/v3/marketdata"
+	"github.com/jmvdr-iscte/TradingBotCli/initialize"
+	"github.com/jmvdr-iscte/TradingBotCli/logger"
+	"github.com/rs/zerolog/log"
+	"github.com/shopspring/decimal"
+)
+
+// Quote is the latest bid and ask of a stock, with its spread and age.
+type Quote struct {
+	Bid       float64
+	Ask       float64
+	SpreadBps float64
+	Age       time.Duration
+}
+
+// Mid returns the price between the bid and the ask.
+func (q Quote) Mid() float64 {
+	return (q.Bid + q.Ask) / 2
+}
+
+// GetSnapshotQuote returns the latest quote of the IEX snapshot of a stock.
+func (client *AlpacaClient) GetSnapshotQuote(symbol string) (Quote, error) {
+	snapshot, err := client.dataClient.GetSnapshot(symbol, marketdata.GetSnapshotRequest{
+		Feed:     marketdata.IEX,
+		Currency: "USD",
+	})
+	if err != nil {
+		return Quote{}, fmt.Errorf("get snapshot: %w", err)
+	}
+	if snapshot == nil || snapshot.LatestQuote == nil {
+		return Quote{}, fmt.Errorf("snapshot or latest quote is nil")
+	}
+
+	quote := Quote{
+		Bid: snapshot.LatestQuote.BidPrice,
+		Ask: snapshot.LatestQuote.AskPrice,
+		Age: time.Since(snapshot.LatestQuote.Timestamp),
+	}
+	if quote.Bid > 0 && quote.Ask > 0 {
+		quote.SpreadBps = (quote.Ask - quote.Bid) / quote.Mid() * 10000
+	}
+	return quote, nil
+}
+
+// guardOrder checks the quote before a market order that opens a position is sent.
+// If the quote is stale, one sided or its spread is wider than the max spread, the
+// order is refused with a SkipError, or turned into a marketable limit order that
+// fills at most the max slippage away from the quote. With the limit execution the
+// order is left as is, it is already capped. Closing orders are left as market
+// orders, a close that doesn't fill would leave the position without its stop.
+func (client *AlpacaClient) guardOrder(req *alpaca.PlaceOrderRequest, opening bool) error {
+	cfg := client.execution
+	if cfg == nil || !opening || (cfg.MaxSpreadBps <= 0 && cfg.MaxQuoteAge <= 0) {
+		return nil
+	}
+
+	quote, err := client.GetSnapshotQuote(req.Symbol)
+	if err != nil {
+		return &SkipError{Symbol: req.Symbol, Side: req.Side, Reason: fmt.Sprintf("unable to check the spread: %s", err)}
+	}
+
+	var reason string
+	switch {
+	case quote.Bid <= 0 || quote.Ask <= 0:
+		reason = "no two sided quote"
+	case cfg.MaxQuoteAge > 0 && quote.Age > cfg.MaxQuoteAge:
+		reason = fmt.Sprintf("quote is %s old", quote.Age.Round(time.Second))
+	case cfg.MaxSpreadBps > 0 && quote.SpreadBps > cfg.MaxSpreadBps:
+		reason = fmt.Sprintf("spread of %.1f bps is above %.1f bps", quote.SpreadBps, cfg.MaxSpreadBps)
+	default:
+		return nil
+	}
+
+	price := quote.Ask
+	if req.Side == alpaca.Sell {
+		price = quote.Bid
+	}
+	if cfg.WideSpread != initialize.WideSpreadLimit || price <= 0 {
+		return &SkipError{Symbol: req.Symbol, Side: req.Side, Reason: reason}
+	}
+	if cfg.OrderType == initialize.OrderLimit {
+		// The limit execution already caps the slippage.
+		return nil
+	}
+
+	limit := marketableLimit(price, req.Side, cfg.MaxSlippageBps)
+	if req.Notional != nil {
+		// Limit orders can't be notional.
+		qty := req.Notional.Div(decimal.NewFromFloat(price)).Truncate(fractionalDecimals)
+		req.Qty, req.Notional = &qty, nil
+	}
+	req.Type = alpaca.Limit
+	req.LimitPrice = &limit
+	log.Info().Str(logger.Symbol, req.Symbol).Str("limit", limit.String()).Msgf("%s, sending a limit order", reason)
+	return nil
+}
+
+// marketableLimit returns the limit price max_slippage_bps above the ask for a buy,
+// and below the bid for a sell, with the sub-penny precision allowed by the price.
+func marketableLimit(price float64, side alpaca.Side, max_slippage_bps float64) decimal.Decimal {
+	return roundPrice(offsetPrice(price, side, max_slippage_bps))
+}
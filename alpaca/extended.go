@@ -0,0 +1,212 @@
+// Package alpaca provides auxiliary functions to connect with
+// the Alpaca API.
+package alpaca
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/alpacahq/alpaca-trade-api-go/v3/alpaca"
+	"github.com/jmvdr-iscte/TradingBotCli/logger"
+	"github.com/jmvdr-iscte/TradingBotCli/models"
+	"github.com/rs/zerolog/log"
+	"github.com/shopspring/decimal"
+)
+
+const (
+	// preMarketLength is how long before the open the pre-market starts, at 4:00 ET.
+	preMarketLength = 5*time.Hour + 30*time.Minute
+	// afterHoursLength is how long after the close the after-hours end.
+	afterHoursLength = 4 * time.Hour
+)
+
+// MarketSession is the part of the trading day the market is in.
+type MarketSession int
+
+const (
+	SessionClosed MarketSession = iota
+	SessionPreMarket
+	SessionRegular
+	SessionAfterHours
+)
+
+// String returns the name of the session.
+func (s MarketSession) String() string {
+	return [...]string{"closed", "pre-market", "regular", "after-hours"}[s]
+}
+
+// Extended returns true for the pre-market and the after-hours.
+func (s MarketSession) Extended() bool {
+	return s == SessionPreMarket || s == SessionAfterHours
+}
+
+// GetMarketSession returns the session the market is in now.
+func (client *AlpacaClient) GetMarketSession() (MarketSession, error) {
+	clock, err := client.tradeClient.GetClock()
+	if err != nil {
+		return SessionClosed, fmt.Errorf("get clock: %w", err)
+	}
+	if clock.IsOpen {
+		return SessionRegular, nil
+	}
+
+	open, closing, err := client.GetSessionHours()
+	if err != nil {
+		return SessionClosed, err
+	}
+	if open.IsZero() {
+		return SessionClosed, nil
+	}
+
+	now := time.Now()
+	switch {
+	case now.After(open.Add(-preMarketLength)) && now.Before(open):
+		return SessionPreMarket, nil
+	case !now.Before(closing) && now.Before(closing.Add(afterHoursLength)):
+		return SessionAfterHours, nil
+	}
+	return SessionClosed, nil
+}
+
+// ExtendedHours returns true if the extended hours trading is enabled.
+func (client *AlpacaClient) ExtendedHours() bool {
+	return client.extended != nil && client.extended.Enabled
+}
+
+// IsSessionOpen returns true if the bot can trade now, in the regular session or, when the
+// extended hours trading is enabled, in the pre-market and the after-hours.
+func (client *AlpacaClient) IsSessionOpen() (bool, error) {
+	open, err := client.IsMarketOpen()
+	if err != nil || open || !client.ExtendedHours() {
+		return open, err
+	}
+
+	session, err := client.GetMarketSession()
+	if err != nil {
+		return false, err
+	}
+	if session.Extended() {
+		log.Info().Str("session", session.String()).Msg("trading in the extended session")
+	}
+	return session.Extended(), nil
+}
+
+// inExtendedSession returns true if the extended hours trading is enabled and the
+// market is in the pre-market or the after-hours.
+func (client *AlpacaClient) inExtendedSession() bool {
+	if !client.ExtendedHours() {
+		return false
+	}
+	session, err := client.GetMarketSession()
+	if err != nil {
+		log.Error().Err(err).Msg("unable to get the market session")
+		return false
+	}
+	return session.Extended()
+}
+
+// extendedSize returns the quantity of an order that opens a position outside the regular
+// session, scaled by the size multiplier and capped by the max position value.
+func (client *AlpacaClient) extendedSize(symbol string, qty decimal.Decimal, side alpaca.Side) decimal.Decimal {
+	if !client.inExtendedSession() {
+		return qty
+	}
+
+	qty = qty.Mul(decimal.NewFromFloat(client.extended.SizeMultiplier))
+	if client.extended.MaxPositionValue > 0 {
+		price, err := client.getLastQuote(symbol, side)
+		if err == nil && price > 0 {
+			qty = decimal.Min(qty, decimal.NewFromFloat(client.extended.MaxPositionValue/price))
+		}
+	}
+	return qty.Floor()
+}
+
+// OnSoftStop registers a function that is called with the stop loss of a position
+// opened outside the regular session, which the broker would not trigger.
+func (client *AlpacaClient) OnSoftStop(fn func(stop models.SoftStop)) {
+	client.onSoftStop = fn
+}
+
+// PlaceStop places a day stop order at the price of the soft stop, for the quantity
+// held of the symbol. It returns nil if there is no position, or if the position already
+// has a stop order, like the GTC stop of a position held overnight.
+func (client *AlpacaClient) PlaceStop(stop models.SoftStop) error {
+	qty, err := client.GetPositionQty(stop.Symbol)
+	if err != nil || qty.IsZero() {
+		return err
+	}
+
+	orders, err := client.tradeClient.GetOrders(alpaca.GetOrdersRequest{
+		Status:  "open",
+		Symbols: []string{stop.Symbol},
+	})
+	if err != nil {
+		return fmt.Errorf("get open orders: %w", err)
+	}
+	for _, order := range orders {
+		if order.Type == alpaca.Stop || order.Type == alpaca.TrailingStop {
+			log.Info().Str(logger.Symbol, stop.Symbol).Str(logger.OrderID, order.ID).Str("time_in_force", string(order.TimeInForce)).
+				Msg("the position already has a stop order, dropping the soft stop")
+			return nil
+		}
+	}
+
+	qty = qty.Abs()
+	price := decimal.NewFromFloat(stop.Price)
+	if client.dryRun {
+		log.Info().Str(logger.Symbol, stop.Symbol).Str("qty", qty.String()).Str("side", stop.Side).Str("stop_price", price.String()).
+			Msg("[dry-run] would place the stop order")
+		return nil
+	}
+	_, err = client.tradeClient.PlaceOrder(alpaca.PlaceOrderRequest{
+		Symbol:      stop.Symbol,
+		Qty:         &qty,
+		Side:        alpaca.Side(stop.Side),
+		Type:        alpaca.Stop,
+		StopPrice:   &price,
+		TimeInForce: alpaca.Day,
+	})
+	if err != nil {
+		return fmt.Errorf("unable to set a stop loss: %w", err)
+	}
+	return nil
+}
+
+// GetStopOrders returns the open stop orders as soft stops, so they can be watched
+// by the bot once the regular session ends.
+func (client *AlpacaClient) GetStopOrders() ([]models.SoftStop, error) {
+	orders, err := client.tradeClient.GetOrders(alpaca.GetOrdersRequest{
+		Status: "open",
+	})
+	if err != nil {
+		return nil, fmt.Errorf("get open orders: %w", err)
+	}
+
+	var stops []models.SoftStop
+	for _, order := range orders {
+		if order.Type != alpaca.Stop || order.StopPrice == nil {
+			continue
+		}
+		stops = append(stops, models.SoftStop{
+			Symbol: order.Symbol,
+			Side:   string(order.Side),
+			Price:  order.StopPrice.InexactFloat64(),
+		})
+	}
+	return stops, nil
+}
+
+// GetSessionEnd returns when the bot stops trading today, the close or, when the extended
+// hours trading is enabled, the end of the after-hours. It returns the zero time if the
+// market does not open today.
+func (client *AlpacaClient) GetSessionEnd() (time.Time, error) {
+	_, closing, err := client.GetSessionHours()
+	if err != nil || closing.IsZero() {
+		return closing, err
+	}
+	if client.ExtendedHours() {
+		return closing.Add(afterHoursLength), nil
+	}
+	return closing, nil
+}
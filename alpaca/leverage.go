@@ -0,0 +1,45 @@
+// Package alpaca provides auxiliary functions to connect with
+// the Alpaca API.
+package alpaca
+
+import (
+	"fmt"
+
+	"github.com/jmvdr-iscte/TradingBotCli/risk"
+	"github.com/rs/zerolog/log"
+)
+
+// maintenanceRate is the maintenance margin required for a new position, as a fraction of
+// its value. Alpaca requires between 25% and 30% for most stocks, the highest is used.
+const maintenanceRate = 0.30
+
+// dayTradingPower returns the buying power an order of a profile with leverage is sized
+// from. For accounts above the pattern day trader equity it is the day trading buying
+// power, limited by the leverage and the maintenance margin caps of the profile. It
+// returns false if the account or the profile don't use the day trading buying power.
+func (client *AlpacaClient) dayTradingPower(profile risk.Profile) (float64, bool, error) {
+	if !profile.Leverage.Enabled || client.cash != nil {
+		return 0, false, nil
+	}
+
+	account, err := client.tradeClient.GetAccount()
+	if err != nil {
+		return 0, false, fmt.Errorf("get account %w", err)
+	}
+	equity := account.Equity.InexactFloat64()
+	if equity < PDTEquity {
+		return 0, false, nil
+	}
+
+	day_trading := account.DaytradingBuyingPower.InexactFloat64()
+	exposure := account.LongMarketValue.Abs().InexactFloat64() + account.ShortMarketValue.Abs().InexactFloat64()
+	leverage_room := profile.Leverage.MaxLeverage*equity - exposure
+	maintenance_room := (profile.Leverage.MaxMaintenance*equity - account.MaintenanceMargin.InexactFloat64()) / maintenanceRate
+
+	power := max(min(day_trading, leverage_room, maintenance_room), 0)
+	if power < day_trading {
+		log.Info().Float64("day_trading_buying_power", day_trading).Float64("capped", power).Str("risk", profile.Name).
+			Msg("day trading buying power capped by the leverage of the profile")
+	}
+	return power, true, nil
+}
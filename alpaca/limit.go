@@ -0,0 +1,198 @@
+// Package alpaca provides auxiliary functions to connect with
+// the Alpaca API.
+package alpaca
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/alpacahq/alpaca-trade-api-go/v3/alpaca"
+	"github.com/jmvdr-iscte/TradingBotCli/initialize"
+	"github.com/jmvdr-iscte/TradingBotCli/logger"
+	"github.com/rs/zerolog/log"
+	"github.com/shopspring/decimal"
+)
+
+// finalStatuses are the statuses of the orders that won't fill anymore.
+var finalStatuses = map[string]bool{
+	"filled":       true,
+	"canceled":     true,
+	"expired":      true,
+	"rejected":     true,
+	"done_for_day": true,
+	"replaced":     true,
+}
+
+// executeLimit sends the order as a limit order priced from the quote. Every RepriceAfter
+// the unfilled order is cancelled and the rest is sent again RepriceStepBps closer to the
+// far side of the quote, until the price is MaxSlippageBps away from the mid price at the
+// start. If it is still unfilled at that price it is cancelled. It returns the filled
+// quantity and its average price, which are zero if nothing was filled.
+func (client *AlpacaClient) executeLimit(req alpaca.PlaceOrderRequest) (decimal.Decimal, decimal.Decimal, error) {
+	cfg := client.execution
+
+	quote, err := client.GetSnapshotQuote(req.Symbol)
+	if err != nil {
+		return decimal.Zero, decimal.Zero, err
+	}
+	if quote.Bid <= 0 || quote.Ask <= 0 {
+		return decimal.Zero, decimal.Zero, fmt.Errorf("no two sided quote for %s", req.Symbol)
+	}
+
+	reference := quote.Mid()
+	worst := offsetPrice(reference, req.Side, cfg.MaxSlippageBps)
+	limit := clampPrice(limitPrice(quote, req.Side, cfg), worst, req.Side)
+
+	if req.Notional != nil {
+		// Limit orders can't be notional.
+		qty := req.Notional.Div(decimal.NewFromFloat(reference)).Truncate(fractionalDecimals)
+		req.Notional = nil
+		req.Qty = &qty
+	}
+	remaining := *req.Qty
+	filled, cost := decimal.Zero, decimal.Zero
+
+	for remaining.IsPositive() {
+		limit_price := roundPrice(limit)
+		qty := remaining
+		req.Type = alpaca.Limit
+		req.LimitPrice = &limit_price
+		req.Qty = &qty
+
+		order, err := client.placeOrder(req)
+		if err != nil {
+			if filled.IsPositive() {
+				break
+			}
+			return decimal.Zero, decimal.Zero, fmt.Errorf("place limit order: %w", err)
+		}
+		log.Info().Str(logger.Symbol, req.Symbol).Str(logger.OrderID, order.ID).Str("side", string(req.Side)).
+			Str("qty", qty.String()).Str("limit", limit_price.String()).Msg("limit order placed")
+
+		time.Sleep(cfg.RepriceAfter)
+		order, err = client.finishOrder(order.ID)
+		if err != nil {
+			return filled, averagePrice(filled, cost), err
+		}
+		if order.FilledQty.IsPositive() && order.FilledAvgPrice != nil {
+			filled = filled.Add(order.FilledQty)
+			cost = cost.Add(order.FilledQty.Mul(*order.FilledAvgPrice))
+			remaining = remaining.Sub(order.FilledQty)
+		}
+
+		if limit == worst {
+			if remaining.IsPositive() {
+				log.Warn().Str(logger.Symbol, req.Symbol).Str(logger.OrderID, order.ID).Str("side", string(req.Side)).
+					Str("unfilled", remaining.String()).Msg("limit order reached the max slippage")
+			}
+			break
+		}
+		step := reference * cfg.RepriceStepBps / 10000
+		if req.Side == alpaca.Sell {
+			step = -step
+		}
+		limit = clampPrice(limit+step, worst, req.Side)
+	}
+	return filled, averagePrice(filled, cost), nil
+}
+
+// executeMarketable sends the marketable limit order made by guardOrder as is. After
+// RepriceAfter the unfilled part is cancelled, it is never repriced since the limit is
+// already at the max slippage. It returns the filled quantity and its average price,
+// which are zero if nothing was filled.
+func (client *AlpacaClient) executeMarketable(req alpaca.PlaceOrderRequest) (decimal.Decimal, decimal.Decimal, error) {
+	order, err := client.placeOrder(req)
+	if err != nil {
+		return decimal.Zero, decimal.Zero, fmt.Errorf("place limit order: %w", err)
+	}
+	log.Info().Str(logger.Symbol, req.Symbol).Str(logger.OrderID, order.ID).Str("side", string(req.Side)).
+		Str("qty", req.Qty.String()).Str("limit", req.LimitPrice.String()).Msg("limit order placed")
+
+	time.Sleep(client.execution.RepriceAfter)
+	order, err = client.finishOrder(order.ID)
+	if err != nil {
+		return decimal.Zero, decimal.Zero, err
+	}
+	if !order.FilledQty.IsPositive() || order.FilledAvgPrice == nil {
+		log.Warn().Str(logger.Symbol, req.Symbol).Str(logger.OrderID, order.ID).Str("side", string(req.Side)).
+			Msg("limit order was not filled at the max slippage")
+		return decimal.Zero, decimal.Zero, nil
+	}
+	return order.FilledQty, *order.FilledAvgPrice, nil
+}
+
+// finishOrder cancels the order if it is not filled yet, and returns it once it
+// reached a final status.
+func (client *AlpacaClient) finishOrder(orderId string) (*alpaca.Order, error) {
+	order, err := client.tradeClient.GetOrder(orderId)
+	if err != nil {
+		return nil, fmt.Errorf("get order: %w", err)
+	}
+	if finalStatuses[order.Status] {
+		return order, nil
+	}
+
+	// The order may fill while it is cancelled, its final status tells what happened.
+	if err := client.tradeClient.CancelOrder(orderId); err != nil {
+		log.Error().Err(err).Str(logger.OrderID, orderId).Msg("unable to cancel the order")
+	}
+	for i := 0; i < 20; i++ {
+		time.Sleep(250 * time.Millisecond)
+		order, err = client.tradeClient.GetOrder(orderId)
+		if err != nil {
+			return nil, fmt.Errorf("get order: %w", err)
+		}
+		if finalStatuses[order.Status] {
+			return order, nil
+		}
+	}
+	return nil, fmt.Errorf("order %s is still %s after being cancelled", orderId, order.Status)
+}
+
+// limitPrice returns the first limit price of an order of the given side.
+func limitPrice(quote Quote, side alpaca.Side, cfg *initialize.ExecutionConfig) float64 {
+	switch cfg.LimitPrice {
+	case initialize.LimitTouch:
+		if side == alpaca.Buy {
+			return quote.Bid
+		}
+		return quote.Ask
+	case initialize.LimitOffset:
+		return offsetPrice(quote.Mid(), side, cfg.LimitOffsetBps)
+	default:
+		return quote.Mid()
+	}
+}
+
+// offsetPrice returns the price bps basis points higher for a buy, and lower for a sell.
+func offsetPrice(price float64, side alpaca.Side, bps float64) float64 {
+	if side == alpaca.Sell {
+		bps = -bps
+	}
+	return price * (1 + bps/10000)
+}
+
+// clampPrice returns the price, or the worst price if the price is worse than it.
+func clampPrice(price float64, worst float64, side alpaca.Side) float64 {
+	if side == alpaca.Buy {
+		return math.Min(price, worst)
+	}
+	return math.Max(price, worst)
+}
+
+// roundPrice returns the price with the sub-penny precision allowed by the price.
+func roundPrice(price float64) decimal.Decimal {
+	if price < 1 {
+		return decimal.NewFromFloat(price).Round(4)
+	}
+	return decimal.NewFromFloat(price).Round(2)
+}
+
+// averagePrice returns the average price of the filled quantity, or zero.
+func averagePrice(filled decimal.Decimal, cost decimal.Decimal) decimal.Decimal {
+	if !filled.IsPositive() {
+		return decimal.Zero
+	}
+	return cost.Div(filled)
+}
@@ -0,0 +1,181 @@
+// Package alpaca provides auxiliary functions to connect with
+// the Alpaca API.
+package alpaca
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/alpacahq/alpaca-trade-api-go/v3/alpaca"
+	"github.com/alpacahq/alpaca-trade-api-go/v3/marketdata"
+	"github.com/jmvdr-iscte/TradingBotCli/logger"
+	"github.com/jmvdr-iscte/TradingBotCli/models"
+	"github.com/jmvdr-iscte/TradingBotCli/risk"
+	"github.com/rs/zerolog/log"
+	"github.com/shopspring/decimal"
+)
+
+// CarryOvernight prepares the positions to be held overnight with the limits of the
+// profile. The fractional positions are closed, since they can't have a GTC stop, and
+// then the positions with the lowest P&L are closed until the rest fit the max positions
+// and the max exposure. The day stops of the kept positions are replaced by GTC stops.
+func (client *AlpacaClient) CarryOvernight(profile risk.Profile) error {
+	equity, err := client.GetEquity()
+	if err != nil {
+		return err
+	}
+	holdings, err := client.GetHoldings()
+	if err != nil {
+		return err
+	}
+
+	sort.Slice(holdings, func(i, j int) bool {
+		return holdings[i].UnrealizedPLPercent > holdings[j].UnrealizedPLPercent
+	})
+
+	limits := profile.Overnight
+	exposure, kept := 0.0, 0
+	for _, holding := range holdings {
+		value := math.Abs(holding.MarketValue)
+		var reason string
+		switch {
+		case holding.Qty != math.Trunc(holding.Qty):
+			reason = "fractional positions can't have a GTC stop"
+		case limits.MaxPositions > 0 && kept >= limits.MaxPositions:
+			reason = fmt.Sprintf("max %d overnight positions", limits.MaxPositions)
+		case exposure+value > limits.MaxExposure*equity:
+			reason = fmt.Sprintf("overnight exposure above %.0f%% of the equity", limits.MaxExposure*100)
+		}
+
+		if reason != "" {
+			log.Info().Str(logger.Symbol, holding.Symbol).Str("reason", reason).Msg("closing the position before the close")
+			if err := client.ClosePosition(holding.Symbol); err != nil {
+				return err
+			}
+			continue
+		}
+
+		exposure += value
+		kept++
+		log.Info().Str(logger.Symbol, holding.Symbol).Msg("holding the position overnight")
+		if err := client.holdStop(holding, profile.StopDistance); err != nil {
+			log.Error().Err(err).Str(logger.Symbol, holding.Symbol).Msg("unable to set the overnight stop")
+		}
+	}
+	return nil
+}
+
+// holdStop makes the stop loss of the position good until cancelled. If the position has
+// no stop one is placed at the stop distance from the entry price.
+func (client *AlpacaClient) holdStop(holding models.Holding, stop_distance float64) error {
+	orders, err := client.tradeClient.GetOrders(alpaca.GetOrdersRequest{
+		Status:  "open",
+		Symbols: []string{holding.Symbol},
+	})
+	if err != nil {
+		return fmt.Errorf("get open orders: %w", err)
+	}
+
+	for _, order := range orders {
+		if order.Type != alpaca.Stop || order.TimeInForce == alpaca.GTC {
+			continue
+		}
+		if client.dryRun {
+			log.Info().Str(logger.Symbol, holding.Symbol).Str(logger.OrderID, order.ID).Msg("[dry-run] would make the stop GTC")
+			return nil
+		}
+		_, err := client.tradeClient.ReplaceOrder(order.ID, alpaca.ReplaceOrderRequest{
+			TimeInForce: alpaca.GTC,
+		})
+		if err != nil {
+			return fmt.Errorf("replace stop order: %w", err)
+		}
+		return nil
+	}
+	for _, order := range orders {
+		if order.Type == alpaca.Stop {
+			return nil
+		}
+	}
+
+	side := alpaca.Buy
+	if holding.Qty < 0 {
+		side = alpaca.Sell
+	}
+	qty := decimal.NewFromFloat(math.Abs(holding.Qty))
+	stop_price := stopLossPrice(decimal.NewFromFloat(holding.AvgEntryPrice), side, stop_distance)
+	if client.dryRun {
+		log.Info().Str(logger.Symbol, holding.Symbol).Str("qty", qty.String()).Str("side", string(stopLossSide(side))).
+			Str("stop_price", stop_price.String()).Msg("[dry-run] would place the GTC stop order")
+		return nil
+	}
+	_, err = client.tradeClient.PlaceOrder(alpaca.PlaceOrderRequest{
+		Symbol:      holding.Symbol,
+		Qty:         &qty,
+		Side:        stopLossSide(side),
+		Type:        alpaca.Stop,
+		StopPrice:   &stop_price,
+		TimeInForce: alpaca.GTC,
+	})
+	if err != nil {
+		return fmt.Errorf("unable to set a stop loss: %w", err)
+	}
+	return nil
+}
+
+// GetGap returns the change of the price of a stock since the last close, as a fraction.
+func (client *AlpacaClient) GetGap(symbol string) (float64, error) {
+	snapshot, err := client.dataClient.GetSnapshot(symbol, marketdata.GetSnapshotRequest{
+		Feed:     marketdata.IEX,
+		Currency: "USD",
+	})
+	if err != nil {
+		return 0, fmt.Errorf("get snapshot: %w", err)
+	}
+	if snapshot == nil || snapshot.LatestTrade == nil || snapshot.DailyBar == nil {
+		return 0, fmt.Errorf("snapshot, latest trade or daily bar is nil")
+	}
+
+	// Before the open the daily bar is still the one of the last session.
+	last_close := snapshot.DailyBar.Close
+	location, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		return 0, fmt.Errorf("load market location: %w", err)
+	}
+	today := time.Now().In(location).Format(time.DateOnly)
+	if snapshot.DailyBar.Timestamp.In(location).Format(time.DateOnly) >= today {
+		if snapshot.PrevDailyBar == nil {
+			return 0, fmt.Errorf("previous daily bar is nil")
+		}
+		last_close = snapshot.PrevDailyBar.Close
+	}
+	if last_close <= 0 {
+		return 0, fmt.Errorf("invalid last close of %s", symbol)
+	}
+	return (snapshot.LatestTrade.Price - last_close) / last_close, nil
+}
+
+// GetNews returns the headlines about the symbol published since the given time,
+// as messages about that symbol only.
+func (client *AlpacaClient) GetNews(symbol string, since time.Time) ([]models.Message, error) {
+	news, err := client.dataClient.GetNews(marketdata.GetNewsRequest{
+		Symbols: []string{symbol},
+		Start:   since,
+		Sort:    marketdata.SortAsc,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("get news: %w", err)
+	}
+
+	messages := make([]models.Message, 0, len(news))
+	for _, article := range news {
+		messages = append(messages, models.Message{
+			ID:       int64(article.ID),
+			Headline: article.Headline,
+			Symbols:  []string{symbol},
+		})
+	}
+	return messages, nil
+}
@@ -0,0 +1,170 @@
+// Package alpaca provides auxiliary functions to connect with
+// the Alpaca API.
+package alpaca
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/alpacahq/alpaca-trade-api-go/v3/alpaca"
+	"github.com/jmvdr-iscte/TradingBotCli/logger"
+	"github.com/jmvdr-iscte/TradingBotCli/pdt"
+	"github.com/jmvdr-iscte/TradingBotCli/session"
+	"github.com/rs/zerolog/log"
+)
+
+// PDTRestricted returns true if the account is subject to the day trade limit,
+// a margin account with less than 25.000$ of equity.
+func (client *AlpacaClient) PDTRestricted() (bool, error) {
+	account, err := client.tradeClient.GetAccount()
+	if err != nil {
+		return false, fmt.Errorf("get account %w", err)
+	}
+	return pdtRestricted(account), nil
+}
+
+// pdtRestricted returns true if the account is subject to the day trade limit.
+func pdtRestricted(account *alpaca.Account) bool {
+	return account.Multiplier.GreaterThan(one) && account.Equity.InexactFloat64() < PDTEquity
+}
+
+// GetDayTradeWindowStart returns the start of the rolling window of five business days
+// that ends today, using the market calendar so the holidays are skipped.
+func (client *AlpacaClient) GetDayTradeWindowStart() (time.Time, error) {
+	location, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		return time.Time{}, fmt.Errorf("load market location: %w", err)
+	}
+
+	today := time.Now().In(location)
+	days, err := client.tradeClient.GetCalendar(alpaca.GetCalendarRequest{
+		Start: today.AddDate(0, 0, -14),
+		End:   today,
+	})
+	if err != nil {
+		return time.Time{}, fmt.Errorf("get calendar: %w", err)
+	}
+	if len(days) == 0 {
+		return today.AddDate(0, 0, -7), nil
+	}
+
+	first := days[max(len(days)-pdt.BusinessDays, 0)]
+	start, err := time.ParseInLocation(time.DateOnly, first.Date, location)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("parse calendar date: %w", err)
+	}
+	return start, nil
+}
+
+// DayTradeStatus returns the day trading status of the account in the given trading date.
+// The day trades used are the most of the ledger and of the broker, which also counts the
+// ones made before the ledger was kept or outside of the bot.
+func (client *AlpacaClient) DayTradeStatus(ctx context.Context, ledger *pdt.Ledger, date string) (pdt.Status, error) {
+	account, err := client.tradeClient.GetAccount()
+	if err != nil {
+		return pdt.Status{}, fmt.Errorf("get account %w", err)
+	}
+	since, err := client.GetDayTradeWindowStart()
+	if err != nil {
+		return pdt.Status{}, err
+	}
+	status, err := ledger.Status(ctx, date, since, pdtRestricted(account))
+	if err != nil {
+		return status, err
+	}
+	status.Used = max(status.Used, int(account.DaytradeCount))
+	return status, nil
+}
+
+// CheckDayTradeOpen returns a SkipError if the ledger skips the entries that could not be
+// closed the same day, and there are not enough day trades left for one more position.
+func (client *AlpacaClient) CheckDayTradeOpen(ctx context.Context, ledger *pdt.Ledger, symbol string, side alpaca.Side) error {
+	if ledger == nil || ledger.Policy != pdt.PolicySkip {
+		return nil
+	}
+	status, err := client.DayTradeStatus(ctx, ledger, session.TradingDate(time.Now()))
+	if err != nil {
+		return err
+	}
+	if !status.CanOpen() {
+		return &SkipError{
+			Symbol: symbol,
+			Side:   side,
+			Rule:   "pdt",
+			Reason: fmt.Sprintf("%d day trades used and %d positions opened today, it could not be closed today", status.Used, status.OpenedToday),
+		}
+	}
+	return nil
+}
+
+// CheckDayTradeClose returns a SkipError if closing the position of the symbol today would be
+// a day trade over the limit, in which case the position is held overnight.
+func (client *AlpacaClient) CheckDayTradeClose(ctx context.Context, ledger *pdt.Ledger, symbol string) error {
+	if ledger == nil {
+		return nil
+	}
+	date := session.TradingDate(time.Now())
+	opened_today, err := ledger.OpenedToday(ctx, date, symbol)
+	if err != nil || !opened_today {
+		return err
+	}
+	status, err := client.DayTradeStatus(ctx, ledger, date)
+	if err != nil {
+		return err
+	}
+	if !status.CanClose(opened_today) {
+		return &SkipError{
+			Symbol: symbol,
+			Side:   "close",
+			Rule:   "pdt",
+			Reason: "closing would be a day trade with none left, holding overnight",
+		}
+	}
+	return nil
+}
+
+// ClosePositionsWithin closes every position that can be closed without going over the day
+// trade limit. The positions opened today that can't are held overnight with a stop good
+// until cancelled at the stop distance, it returns their symbols.
+func (client *AlpacaClient) ClosePositionsWithin(ctx context.Context, ledger *pdt.Ledger, stop_distance float64) ([]string, error) {
+	if ledger == nil {
+		return nil, client.ClosePositions()
+	}
+	date := session.TradingDate(time.Now())
+	status, err := client.DayTradeStatus(ctx, ledger, date)
+	if err != nil {
+		return nil, err
+	}
+	if !status.Restricted {
+		return nil, client.ClosePositions()
+	}
+
+	holdings, err := client.GetHoldings()
+	if err != nil {
+		return nil, err
+	}
+	var held []string
+	remaining := status.Remaining()
+	for _, holding := range holdings {
+		opened_today, err := ledger.OpenedToday(ctx, date, holding.Symbol)
+		if err != nil {
+			return held, err
+		}
+		if opened_today && remaining == 0 {
+			log.Warn().Str(logger.Symbol, holding.Symbol).Msg("holding overnight, closing it would be a day trade with none left")
+			if err := client.holdStop(holding, stop_distance); err != nil {
+				log.Error().Err(err).Str(logger.Symbol, holding.Symbol).Msg("unable to set the overnight stop")
+			}
+			held = append(held, holding.Symbol)
+			continue
+		}
+		if err := client.ClosePosition(holding.Symbol); err != nil {
+			return held, fmt.Errorf("unable to close %s %w", holding.Symbol, err)
+		}
+		if opened_today {
+			remaining--
+		}
+	}
+	return held, nil
+}
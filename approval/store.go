@@ -0,0 +1,166 @@
+// Package approval parks the trades decided by the bot until a human approves them,
+// through the CLI, the HTTP API or a chat webhook. A decision that is not approved
+// within the timeout expires.
+package approval
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jmvdr-iscte/TradingBotCli/models"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	decisionPrefix = "approval:"
+	pendingKey     = "approval:pending"
+)
+
+// ErrNotFound is returned when a decision does not exist, it expired or was already handled.
+var ErrNotFound = errors.New("decision not found or expired")
+
+// Decision is a trade waiting for approval. The quantity and the value are the ones
+// at the time of the decision, they are sized again once it is approved.
+type Decision struct {
+	ID        string         `json:"id"`
+	Symbol    string         `json:"symbol"`
+	Side      string         `json:"side"`
+	Qty       float64        `json:"qty"`
+	Value     float64        `json:"value"`
+	Score     int            `json:"score"`
+	Headline  string         `json:"headline"`
+	Buy       bool           `json:"buy"`
+	Message   models.Message `json:"message"`
+	CreatedAt time.Time      `json:"created_at"`
+	ExpiresAt time.Time      `json:"expires_at"`
+}
+
+// String returns the decision as a single line.
+func (d Decision) String() string {
+	return fmt.Sprintf("%s %s %s %g ($%.2f) score %d, expires %s: %q",
+		d.ID, d.Side, d.Symbol, d.Qty, d.Value, d.Score, d.ExpiresAt.Format(time.TimeOnly), d.Headline)
+}
+
+// Store keeps the pending decisions in redis.
+type Store struct {
+	client   *redis.Client
+	Timeout  time.Duration
+	MinValue float64
+}
+
+// NewStore returns a new Store whose decisions expire after the timeout. Only the trades
+// worth at least minValue need approval.
+func NewStore(client *redis.Client, timeout time.Duration, minValue float64) *Store {
+	return &Store{
+		client:   client,
+		Timeout:  timeout,
+		MinValue: minValue,
+	}
+}
+
+// Required returns true if a trade of the given value needs approval.
+func (s *Store) Required(value float64) bool {
+	return value >= s.MinValue
+}
+
+// Park saves the decision until it is approved, rejected or it expires. It returns the
+// decision with its id and expiration.
+func (s *Store) Park(ctx context.Context, decision Decision) (Decision, error) {
+	id := make([]byte, 4)
+	if _, err := rand.Read(id); err != nil {
+		return decision, fmt.Errorf("unable to generate the decision id: %w", err)
+	}
+	decision.ID = hex.EncodeToString(id)
+	decision.CreatedAt = time.Now()
+	decision.ExpiresAt = decision.CreatedAt.Add(s.Timeout)
+
+	data, err := json.Marshal(decision)
+	if err != nil {
+		return decision, fmt.Errorf("unable to marshal the decision: %w", err)
+	}
+	_, err = s.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Set(ctx, decisionPrefix+decision.ID, data, s.Timeout)
+		pipe.ZAdd(ctx, pendingKey, redis.Z{Score: float64(decision.ExpiresAt.Unix()), Member: decision.ID})
+		return nil
+	})
+	if err != nil {
+		return decision, fmt.Errorf("unable to park the decision of %s: %w", decision.Symbol, err)
+	}
+	return decision, nil
+}
+
+// Pending returns the decisions waiting for approval, the oldest first.
+func (s *Store) Pending(ctx context.Context) ([]Decision, error) {
+	now := fmt.Sprint(time.Now().Unix())
+	if err := s.client.ZRemRangeByScore(ctx, pendingKey, "-inf", now).Err(); err != nil {
+		return nil, fmt.Errorf("unable to remove the expired decisions: %w", err)
+	}
+	ids, err := s.client.ZRange(ctx, pendingKey, 0, -1).Result()
+	if err != nil {
+		return nil, fmt.Errorf("unable to get the pending decisions: %w", err)
+	}
+
+	decisions := make([]Decision, 0, len(ids))
+	for _, id := range ids {
+		data, err := s.client.Get(ctx, decisionPrefix+id).Bytes()
+		if err == redis.Nil {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("unable to get the decision %s: %w", id, err)
+		}
+		var decision Decision
+		if err := json.Unmarshal(data, &decision); err != nil {
+			return nil, fmt.Errorf("unable to parse the decision %s: %w", id, err)
+		}
+		decisions = append(decisions, decision)
+	}
+	return decisions, nil
+}
+
+// Restore parks again a decision that was taken but could not be handled, until it
+// expires. It returns ErrNotFound if it already expired.
+func (s *Store) Restore(ctx context.Context, decision Decision) error {
+	ttl := time.Until(decision.ExpiresAt)
+	if ttl <= 0 {
+		return ErrNotFound
+	}
+	data, err := json.Marshal(decision)
+	if err != nil {
+		return fmt.Errorf("unable to marshal the decision: %w", err)
+	}
+	_, err = s.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Set(ctx, decisionPrefix+decision.ID, data, ttl)
+		pipe.ZAdd(ctx, pendingKey, redis.Z{Score: float64(decision.ExpiresAt.Unix()), Member: decision.ID})
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("unable to restore the decision %s: %w", decision.ID, err)
+	}
+	return nil
+}
+
+// Take removes the pending decision and returns it, so it is only approved or rejected
+// once. It returns ErrNotFound if there is no such pending decision.
+func (s *Store) Take(ctx context.Context, id string) (Decision, error) {
+	var decision Decision
+	data, err := s.client.GetDel(ctx, decisionPrefix+id).Bytes()
+	if err == redis.Nil {
+		return decision, ErrNotFound
+	}
+	if err != nil {
+		return decision, fmt.Errorf("unable to take the decision %s: %w", id, err)
+	}
+	if err := s.client.ZRem(ctx, pendingKey, id).Err(); err != nil {
+		return decision, fmt.Errorf("unable to remove the decision %s: %w", id, err)
+	}
+	if err := json.Unmarshal(data, &decision); err != nil {
+		return decision, fmt.Errorf("unable to parse the decision %s: %w", id, err)
+	}
+	return decision, nil
+}
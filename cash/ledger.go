@@ -0,0 +1,115 @@
+// Package cash keeps the settlement of the trades of a cash account, which can only
+// trade with settled funds, so the bot never sizes from unsettled proceeds and can
+// tell when a sale would be a good-faith violation.
+package cash
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	unsettledKey = "cash:unsettled"
+	fundedKey    = "cash:funded"
+	ordersKey    = "cash:orders"
+	ordersTTL    = 14 * 24 * time.Hour
+)
+
+// Ledger records in redis the proceeds of the sales until they settle, and the
+// positions bought before the funds used to buy them settled.
+type Ledger struct {
+	client *redis.Client
+}
+
+// NewLedger returns a new Ledger that uses the given redis client.
+func NewLedger(client *redis.Client) *Ledger {
+	return &Ledger{
+		client: client,
+	}
+}
+
+// AddProceeds records the proceeds of a sale of the symbol, unsettled until the given time.
+func (l *Ledger) AddProceeds(ctx context.Context, symbol string, amount float64, settles time.Time) error {
+	member := fmt.Sprintf("%s:%d:%f", symbol, time.Now().UnixNano(), amount)
+	if err := l.client.ZAdd(ctx, unsettledKey, redis.Z{Score: float64(settles.Unix()), Member: member}).Err(); err != nil {
+		return fmt.Errorf("unable to record the proceeds of %s: %w", symbol, err)
+	}
+	return nil
+}
+
+// Record records that the settlement of the order was taken into account. It returns
+// false if it already was.
+func (l *Ledger) Record(ctx context.Context, order_id string) (bool, error) {
+	var added *redis.IntCmd
+	_, err := l.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		added = pipe.SAdd(ctx, ordersKey, order_id)
+		pipe.Expire(ctx, ordersKey, ordersTTL)
+		return nil
+	})
+	if err != nil {
+		return false, fmt.Errorf("unable to record the order %s: %w", order_id, err)
+	}
+	return added.Val() > 0, nil
+}
+
+// Unsettled returns the proceeds that are not settled at the given time, and the time
+// the last of them settles. The settled proceeds are removed.
+func (l *Ledger) Unsettled(ctx context.Context, now time.Time) (float64, time.Time, error) {
+	if err := l.client.ZRemRangeByScore(ctx, unsettledKey, "-inf", fmt.Sprint(now.Unix())).Err(); err != nil {
+		return 0, time.Time{}, fmt.Errorf("unable to remove the settled proceeds: %w", err)
+	}
+	proceeds, err := l.client.ZRangeWithScores(ctx, unsettledKey, 0, -1).Result()
+	if err != nil {
+		return 0, time.Time{}, fmt.Errorf("unable to get the unsettled proceeds: %w", err)
+	}
+
+	var (
+		total float64
+		last  time.Time
+	)
+	for _, z := range proceeds {
+		member, _ := z.Member.(string)
+		amount, err := strconv.ParseFloat(member[strings.LastIndex(member, ":")+1:], 64)
+		if err != nil {
+			return 0, time.Time{}, fmt.Errorf("unable to parse the proceeds %q: %w", member, err)
+		}
+		total += amount
+		last = time.Unix(int64(z.Score), 0)
+	}
+	return total, last, nil
+}
+
+// Buy records that the position in the symbol was bought with funds that settle at the
+// given time. Selling it before then is a good-faith violation.
+func (l *Ledger) Buy(ctx context.Context, symbol string, settles time.Time) error {
+	if err := l.client.HSet(ctx, fundedKey, symbol, settles.Unix()).Err(); err != nil {
+		return fmt.Errorf("unable to record the funding of %s: %w", symbol, err)
+	}
+	return nil
+}
+
+// Sold deletes the funding of the position in the symbol.
+func (l *Ledger) Sold(ctx context.Context, symbol string) error {
+	if err := l.client.HDel(ctx, fundedKey, symbol).Err(); err != nil {
+		return fmt.Errorf("unable to delete the funding of %s: %w", symbol, err)
+	}
+	return nil
+}
+
+// GoodFaithViolation returns true if selling the position in the symbol at the given
+// time is a good-faith violation, because the funds that bought it are not settled.
+func (l *Ledger) GoodFaithViolation(ctx context.Context, symbol string, now time.Time) (bool, error) {
+	settles, err := l.client.HGet(ctx, fundedKey, symbol).Int64()
+	if err == redis.Nil {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("unable to get the funding of %s: %w", symbol, err)
+	}
+	return now.Unix() < settles, nil
+}
@@ -10,6 +10,7 @@ import (
 	"github.com/jmvdr-iscte/TradingBotCli/initialize"
 	news "github.com/jmvdr-iscte/TradingBotCli/server"
 
+	"github.com/rs/zerolog/log"
 	"golang.org/x/net/websocket"
 )
 
@@ -22,7 +23,7 @@ func ConnectToWebSocket(s *news.NewsServer) error {
 	cfg := initialize.LoadAlpaca()
 	serverURL := NewsURL
 	wsConfig, err := websocket.NewConfig(serverURL, cfg.Url)
-	fmt.Println("trying to connect to socket")
+	log.Info().Str("url", serverURL).Msg("trying to connect to socket")
 
 	if err != nil {
 		return fmt.Errorf("error when connecting to the websocket: %w", err)
@@ -35,7 +36,7 @@ func ConnectToWebSocket(s *news.NewsServer) error {
 		return fmt.Errorf("error dialing configs: %w", err)
 	}
 
-	isMarketOpen, err := s.AlpacaClient.IsMarketOpen()
+	isMarketOpen, err := s.AlpacaClient.IsSessionOpen()
 	if err != nil {
 		return fmt.Errorf("unable to check the market conditions %w", err)
 	}
@@ -45,10 +46,10 @@ func ConnectToWebSocket(s *news.NewsServer) error {
 		return fmt.Errorf("unable to check the current trades %w", err)
 	}
 
-	if !isMarketOpen || !haveTrades {
+	if !isMarketOpen || (!haveTrades && s.Ledger == nil) {
 		err = ws.Close()
 		if err != nil {
-			fmt.Println("Error Closing the websocket", err)
+			log.Error().Err(err).Msg("error closing the websocket")
 			return nil
 		}
 		s.Mu.Lock()
@@ -0,0 +1,188 @@
+// Package compliance checks every order that opens or increases a position
+// against a set of rules declared in the configuration. It does not depend on
+// the Alpaca API, the account state is given by the caller.
+package compliance
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// Order is an order that opens or increases a position.
+type Order struct {
+	Symbol string
+	Buy    bool
+	Qty    float64
+	Price  float64
+	Time   time.Time
+}
+
+// Value returns the signed value of the order, negative for sells.
+func (o Order) Value() float64 {
+	if o.Buy {
+		return o.Qty * o.Price
+	}
+	return -o.Qty * o.Price
+}
+
+// Position is an open position, Qty and MarketValue are negative for shorts.
+type Position struct {
+	Symbol      string
+	Qty         float64
+	MarketValue float64
+}
+
+// State is the state of the account and the market when the order is checked.
+type State struct {
+	Positions   []Position
+	Bid         float64
+	Ask         float64
+	MarketOpen  time.Time
+	MarketClose time.Time
+	LastTrade   time.Time
+}
+
+// position returns the position of the symbol, if there is one.
+func (s State) position(symbol string) (Position, bool) {
+	for _, position := range s.Positions {
+		if position.Symbol == symbol {
+			return position, true
+		}
+	}
+	return Position{}, false
+}
+
+// Rule is a single pre-trade check.
+type Rule interface {
+	Name() string
+	Check(order Order, state State) error
+}
+
+// Rejection is returned when an order breaks a rule.
+type Rejection struct {
+	Rule   string
+	Reason string
+}
+
+// Error returns the rule and the reason of the rejection.
+func (r *Rejection) Error() string {
+	return fmt.Sprintf("rule %s: %s", r.Rule, r.Reason)
+}
+
+// Engine runs every rule against an order.
+type Engine struct {
+	rules []Rule
+}
+
+// NewEngine returns an engine with the given rules.
+func NewEngine(rules ...Rule) *Engine {
+	return &Engine{
+		rules: rules,
+	}
+}
+
+// Check returns a Rejection with the first rule the order breaks, or nil if it
+// passes all of them. A nil engine has no rules.
+func (e *Engine) Check(order Order, state State) error {
+	if e == nil {
+		return nil
+	}
+	for _, rule := range e.rules {
+		if err := rule.Check(order, state); err != nil {
+			return &Rejection{Rule: rule.Name(), Reason: err.Error()}
+		}
+	}
+	return nil
+}
+
+// Config declares the rules, the rules with a zero value are disabled.
+type Config struct {
+	MaxPositionValue float64  `json:"max_position_value"`
+	MaxGrossExposure float64  `json:"max_gross_exposure"`
+	MaxNetExposure   float64  `json:"max_net_exposure"`
+	MaxOpenPositions int      `json:"max_open_positions"`
+	MinPrice         float64  `json:"min_price"`
+	MaxSpreadBps     float64  `json:"max_spread_bps"`
+	Allow            []string `json:"allow"`
+	Deny             []string `json:"deny"`
+	SkipOpenMinutes  int      `json:"skip_open_minutes"`
+	SkipCloseMinutes int      `json:"skip_close_minutes"`
+	Cooldown         string   `json:"cooldown"`
+}
+
+// Load returns the engine with the rules declared in the given json file. If the
+// path is empty or no rule is enabled it returns nil, so the orders are not checked.
+// It returns an error if the file is invalid.
+func Load(path string) (*Engine, error) {
+	if path == "" {
+		return nil, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read compliance file: %w", err)
+	}
+
+	var cfg Config
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("unable to parse compliance file: %w", err)
+	}
+	return cfg.Engine()
+}
+
+// Engine returns the engine with the enabled rules of the config, or nil if none is
+// enabled. It returns an error if the config is invalid.
+func (cfg Config) Engine() (*Engine, error) {
+	var rules []Rule
+
+	if len(cfg.Allow) > 0 || len(cfg.Deny) > 0 {
+		rules = append(rules, SymbolList{Allow: upper(cfg.Allow), Deny: upper(cfg.Deny)})
+	}
+	if cfg.MinPrice > 0 {
+		rules = append(rules, MinPrice{Min: cfg.MinPrice})
+	}
+	if cfg.MaxSpreadBps > 0 {
+		rules = append(rules, MaxSpread{MaxBps: cfg.MaxSpreadBps})
+	}
+	if cfg.SkipOpenMinutes > 0 || cfg.SkipCloseMinutes > 0 {
+		rules = append(rules, TradingWindow{
+			SkipOpen:  time.Duration(cfg.SkipOpenMinutes) * time.Minute,
+			SkipClose: time.Duration(cfg.SkipCloseMinutes) * time.Minute,
+		})
+	}
+	if cfg.Cooldown != "" {
+		cooldown, err := time.ParseDuration(cfg.Cooldown)
+		if err != nil {
+			return nil, fmt.Errorf("invalid cooldown: %w", err)
+		}
+		rules = append(rules, Cooldown{Duration: cooldown})
+	}
+	if cfg.MaxOpenPositions > 0 {
+		rules = append(rules, MaxOpenPositions{Max: cfg.MaxOpenPositions})
+	}
+	if cfg.MaxPositionValue > 0 {
+		rules = append(rules, MaxPositionValue{Max: cfg.MaxPositionValue})
+	}
+	if cfg.MaxGrossExposure > 0 {
+		rules = append(rules, MaxGrossExposure{Max: cfg.MaxGrossExposure})
+	}
+	if cfg.MaxNetExposure > 0 {
+		rules = append(rules, MaxNetExposure{Max: cfg.MaxNetExposure})
+	}
+	if len(rules) == 0 {
+		return nil, nil
+	}
+	return NewEngine(rules...), nil
+}
+
+// upper returns the symbols in upper case.
+func upper(symbols []string) []string {
+	result := make([]string, len(symbols))
+	for i, symbol := range symbols {
+		result[i] = strings.ToUpper(strings.TrimSpace(symbol))
+	}
+	return result
+}
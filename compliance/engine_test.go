@@ -0,0 +1,119 @@
+package compliance
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestRules(t *testing.T) {
+	open := time.Date(2024, 3, 4, 9, 30, 0, 0, time.UTC)
+	state := State{
+		Positions: []Position{
+			{Symbol: "AAPL", Qty: 10, MarketValue: 1500},
+			{Symbol: "TSLA", Qty: -5, MarketValue: -1000},
+		},
+		Bid:         99.9,
+		Ask:         100.1,
+		MarketOpen:  open,
+		MarketClose: open.Add(390 * time.Minute),
+		LastTrade:   open.Add(60 * time.Minute),
+	}
+	order := Order{Symbol: "MSFT", Buy: true, Qty: 10, Price: 100, Time: open.Add(120 * time.Minute)}
+
+	tests := []struct {
+		name   string
+		rule   Rule
+		order  Order
+		reject bool
+	}{
+		{"denied symbol", SymbolList{Deny: []string{"MSFT"}}, order, true},
+		{"symbol not allowed", SymbolList{Allow: []string{"AAPL"}}, order, true},
+		{"allowed symbol", SymbolList{Allow: []string{"MSFT"}}, order, false},
+		{"price below min", MinPrice{Min: 150}, order, true},
+		{"price above min", MinPrice{Min: 5}, order, false},
+		{"spread too wide", MaxSpread{MaxBps: 10}, order, true},
+		{"spread narrow", MaxSpread{MaxBps: 50}, order, false},
+		{"near the open", TradingWindow{SkipOpen: 3 * time.Hour}, order, true},
+		{"near the close", TradingWindow{SkipClose: 5 * time.Hour}, order, true},
+		{"inside the window", TradingWindow{SkipOpen: time.Hour, SkipClose: time.Hour}, order, false},
+		{"in cooldown", Cooldown{Duration: 2 * time.Hour}, order, true},
+		{"after cooldown", Cooldown{Duration: 30 * time.Minute}, order, false},
+		{"too many positions", MaxOpenPositions{Max: 2}, order, true},
+		{"adding to a position", MaxOpenPositions{Max: 2}, Order{Symbol: "AAPL", Buy: true, Qty: 1, Price: 150}, false},
+		{"position too large", MaxPositionValue{Max: 2000}, Order{Symbol: "AAPL", Buy: true, Qty: 10, Price: 150}, true},
+		{"position within max", MaxPositionValue{Max: 2000}, order, false},
+		{"gross exposure too high", MaxGrossExposure{Max: 3000}, order, true},
+		{"gross exposure within max", MaxGrossExposure{Max: 4000}, order, false},
+		{"net exposure too high", MaxNetExposure{Max: 1000}, order, true},
+		{"short lowers the net exposure", MaxNetExposure{Max: 1000}, Order{Symbol: "MSFT", Qty: 5, Price: 100}, false},
+	}
+	for _, test := range tests {
+		err := test.rule.Check(test.order, state)
+		if (err != nil) != test.reject {
+			t.Errorf("%s: got %v, want rejected %v", test.name, err, test.reject)
+		}
+	}
+}
+
+func TestEngineReturnsTheFirstBrokenRule(t *testing.T) {
+	engine := NewEngine(MinPrice{Min: 5}, SymbolList{Deny: []string{"MSFT"}}, MaxOpenPositions{Max: 0})
+
+	err := engine.Check(Order{Symbol: "MSFT", Buy: true, Qty: 1, Price: 10}, State{})
+	var rejection *Rejection
+	if !errors.As(err, &rejection) {
+		t.Fatalf("got %v, want a rejection", err)
+	}
+	if rejection.Rule != "symbol_list" {
+		t.Errorf("got rule %s, want symbol_list", rejection.Rule)
+	}
+}
+
+func TestNilEngineHasNoRules(t *testing.T) {
+	var engine *Engine
+	if err := engine.Check(Order{Symbol: "MSFT"}, State{}); err != nil {
+		t.Errorf("got %v, want nil", err)
+	}
+}
+
+func TestLoad(t *testing.T) {
+	engine, err := Load("")
+	if err != nil || engine != nil {
+		t.Errorf("empty path: got %v, %v, want no engine", engine, err)
+	}
+
+	dir := t.TempDir()
+	empty := filepath.Join(dir, "empty.json")
+	if err := os.WriteFile(empty, []byte(`{}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	engine, err = Load(empty)
+	if err != nil || engine != nil {
+		t.Errorf("no rules: got %v, %v, want no engine", engine, err)
+	}
+
+	rules := filepath.Join(dir, "rules.json")
+	if err := os.WriteFile(rules, []byte(`{"deny": ["msft"], "min_price": 5, "cooldown": "10m"}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	engine, err = Load(rules)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(engine.rules) != 3 {
+		t.Errorf("got %d rules, want 3", len(engine.rules))
+	}
+	if err := engine.Check(Order{Symbol: "MSFT", Price: 10}, State{}); err == nil {
+		t.Error("got nil, want the lower case deny list to reject MSFT")
+	}
+
+	invalid := filepath.Join(dir, "invalid.json")
+	if err := os.WriteFile(invalid, []byte(`{"cooldown": "soon"}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Load(invalid); err == nil {
+		t.Error("got nil, want an invalid cooldown error")
+	}
+}
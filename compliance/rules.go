@@ -0,0 +1,185 @@
+// Package compliance checks every order that opens or increases a position
+// against a set of rules declared in the configuration. It does not depend on
+// the Alpaca API, the account state is given by the caller.
+package compliance
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"time"
+)
+
+// SymbolList rejects the symbols in the deny list and, if the allow list
+// is not empty, the symbols that are not in it.
+type SymbolList struct {
+	Allow []string
+	Deny  []string
+}
+
+// Name returns the name of the rule.
+func (r SymbolList) Name() string { return "symbol_list" }
+
+// Check returns an error if the order breaks the rule.
+func (r SymbolList) Check(order Order, state State) error {
+	if slices.Contains(r.Deny, order.Symbol) {
+		return fmt.Errorf("%s is in the deny list", order.Symbol)
+	}
+	if len(r.Allow) > 0 && !slices.Contains(r.Allow, order.Symbol) {
+		return fmt.Errorf("%s is not in the allow list", order.Symbol)
+	}
+	return nil
+}
+
+// MinPrice rejects the stocks priced below Min.
+type MinPrice struct {
+	Min float64
+}
+
+// Name returns the name of the rule.
+func (r MinPrice) Name() string { return "min_price" }
+
+// Check returns an error if the order breaks the rule.
+func (r MinPrice) Check(order Order, state State) error {
+	if order.Price < r.Min {
+		return fmt.Errorf("price %.2f is below %.2f", order.Price, r.Min)
+	}
+	return nil
+}
+
+// MaxSpread rejects the stocks with a bid/ask spread wider than MaxBps basis
+// points of the mid price. Stocks without a two sided quote are rejected too.
+type MaxSpread struct {
+	MaxBps float64
+}
+
+// Name returns the name of the rule.
+func (r MaxSpread) Name() string { return "max_spread" }
+
+// Check returns an error if the order breaks the rule.
+func (r MaxSpread) Check(order Order, state State) error {
+	if state.Bid <= 0 || state.Ask <= 0 {
+		return fmt.Errorf("no two sided quote")
+	}
+	mid := (state.Bid + state.Ask) / 2
+	spread := (state.Ask - state.Bid) / mid * 10000
+	if spread > r.MaxBps {
+		return fmt.Errorf("spread of %.1f bps is above %.1f bps", spread, r.MaxBps)
+	}
+	return nil
+}
+
+// TradingWindow rejects the orders in the first SkipOpen and the last
+// SkipClose of the regular session.
+type TradingWindow struct {
+	SkipOpen  time.Duration
+	SkipClose time.Duration
+}
+
+// Name returns the name of the rule.
+func (r TradingWindow) Name() string { return "trading_window" }
+
+// Check returns an error if the order breaks the rule.
+func (r TradingWindow) Check(order Order, state State) error {
+	if !state.MarketOpen.IsZero() && order.Time.Before(state.MarketOpen.Add(r.SkipOpen)) {
+		return fmt.Errorf("less than %s since the open", r.SkipOpen)
+	}
+	if !state.MarketClose.IsZero() && order.Time.After(state.MarketClose.Add(-r.SkipClose)) {
+		return fmt.Errorf("less than %s until the close", r.SkipClose)
+	}
+	return nil
+}
+
+// Cooldown rejects the orders on a symbol traded less than Duration ago.
+type Cooldown struct {
+	Duration time.Duration
+}
+
+// Name returns the name of the rule.
+func (r Cooldown) Name() string { return "cooldown" }
+
+// Check returns an error if the order breaks the rule.
+func (r Cooldown) Check(order Order, state State) error {
+	if !state.LastTrade.IsZero() && order.Time.Sub(state.LastTrade) < r.Duration {
+		return fmt.Errorf("%s was traded less than %s ago", order.Symbol, r.Duration)
+	}
+	return nil
+}
+
+// MaxOpenPositions rejects the orders that would open more than Max positions.
+type MaxOpenPositions struct {
+	Max int
+}
+
+// Name returns the name of the rule.
+func (r MaxOpenPositions) Name() string { return "max_open_positions" }
+
+// Check returns an error if the order breaks the rule.
+func (r MaxOpenPositions) Check(order Order, state State) error {
+	if _, exists := state.position(order.Symbol); exists {
+		return nil
+	}
+	if len(state.Positions) >= r.Max {
+		return fmt.Errorf("%d positions are already open", len(state.Positions))
+	}
+	return nil
+}
+
+// MaxPositionValue rejects the orders that would leave a position worth more than Max.
+type MaxPositionValue struct {
+	Max float64
+}
+
+// Name returns the name of the rule.
+func (r MaxPositionValue) Name() string { return "max_position_value" }
+
+// Check returns an error if the order breaks the rule.
+func (r MaxPositionValue) Check(order Order, state State) error {
+	position, _ := state.position(order.Symbol)
+	value := math.Abs(position.MarketValue + order.Value())
+	if value > r.Max {
+		return fmt.Errorf("position in %s would be worth %.2f, above %.2f", order.Symbol, value, r.Max)
+	}
+	return nil
+}
+
+// MaxGrossExposure rejects the orders that would leave the sum of the absolute
+// value of the positions above Max.
+type MaxGrossExposure struct {
+	Max float64
+}
+
+// Name returns the name of the rule.
+func (r MaxGrossExposure) Name() string { return "max_gross_exposure" }
+
+// Check returns an error if the order breaks the rule.
+func (r MaxGrossExposure) Check(order Order, state State) error {
+	gross := math.Abs(order.Value())
+	for _, position := range state.Positions {
+		gross += math.Abs(position.MarketValue)
+	}
+	if gross > r.Max {
+		return fmt.Errorf("gross exposure would be %.2f, above %.2f", gross, r.Max)
+	}
+	return nil
+}
+
+// MaxNetExposure rejects the orders that would leave the longs minus the shorts above Max.
+type MaxNetExposure struct {
+	Max float64
+}
+
+// Name returns the name of the rule.
+func (r MaxNetExposure) Name() string { return "max_net_exposure" }
+
+// Check returns an error if the order breaks the rule.
+func (r MaxNetExposure) Check(order Order, state State) error {
+	net := order.Value()
+	for _, position := range state.Positions {
+		net += position.MarketValue
+	}
+	if math.Abs(net) > r.Max {
+		return fmt.Errorf("net exposure would be %.2f, above %.2f", net, r.Max)
+	}
+	return nil
+}
@@ -0,0 +1,357 @@
+// Package dashboard draws the live state of the bot in the terminal and lets the user
+// pause the trading, flatten the positions or close a symbol with a key.
+package dashboard
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/hibiken/asynq"
+	"github.com/jmvdr-iscte/TradingBotCli/approval"
+	"github.com/jmvdr-iscte/TradingBotCli/logger"
+	"github.com/jmvdr-iscte/TradingBotCli/models"
+	"github.com/jmvdr-iscte/TradingBotCli/server"
+	"github.com/jmvdr-iscte/TradingBotCli/session"
+	"github.com/jmvdr-iscte/TradingBotCli/worker"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	newsCount   = 10
+	outputLines = 500
+	errorLines  = 50
+)
+
+// Config is what the dashboard reads the state of the bot from.
+type Config struct {
+	Session   *session.Store
+	Approvals *approval.Store
+	RedisOpt  asynq.RedisClientOpt
+	Refresh   time.Duration
+}
+
+// snapshot is the state of the bot drawn by the dashboard.
+type snapshot struct {
+	Time      time.Time
+	Standby   bool
+	Equity    float64
+	Starting  float64
+	Gain      float64
+	HighLimit int
+	LowLimit  int
+	Paused    bool
+	Halted    string
+	News      []models.ScoredNews
+	Holdings  []models.Holding
+	Stops     map[string]float64
+	Pending   int
+	Active    int
+	Scheduled int
+	Approvals []approval.Decision
+	Err       error
+}
+
+// Dashboard draws the state of the bot in the terminal. While it runs, the output of
+// the bot is captured and shown in the dashboard instead of scrolling the terminal.
+// A nil Dashboard does nothing.
+type Dashboard struct {
+	cfg       Config
+	inspector *asynq.Inspector
+	output    *ring
+	errors    *ring
+
+	mu       sync.Mutex
+	server   *server.NewsServer
+	snapshot snapshot
+	selected int
+	confirm  string
+	message  string
+
+	terminal *os.File
+	state    *termState
+	stdout   *os.File
+	stderr   *os.File
+	restore  func()
+	pipe     *os.File
+	done     chan struct{}
+	stop     sync.Once
+}
+
+// New returns a new Dashboard with the given config.
+func New(cfg Config) *Dashboard {
+	if cfg.Refresh <= 0 {
+		cfg.Refresh = 2 * time.Second
+	}
+	return &Dashboard{
+		cfg:    cfg,
+		output: newRing(outputLines),
+		errors: newRing(errorLines),
+		done:   make(chan struct{}),
+	}
+}
+
+// Start puts the terminal in raw mode and starts drawing. It returns an error if the
+// standard input is not a terminal, in which case the output is left as it is.
+func (d *Dashboard) Start() error {
+	state, err := makeRaw(int(os.Stdin.Fd()))
+	if err != nil {
+		return fmt.Errorf("unable to put the terminal in raw mode: %w", err)
+	}
+	d.state = state
+
+	reader, writer, err := os.Pipe()
+	if err != nil {
+		restore(int(os.Stdin.Fd()), d.state)
+		return fmt.Errorf("unable to capture the output: %w", err)
+	}
+	d.terminal, d.stdout, d.stderr, d.pipe = os.Stdout, os.Stdout, os.Stderr, writer
+	os.Stdout, os.Stderr = writer, writer
+	d.restore = logger.Redirect(writer)
+	go capture(reader, d.output, d.errors)
+
+	d.inspector = asynq.NewInspector(d.cfg.RedisOpt)
+	fmt.Fprint(d.terminal, "\x1b[?1049h\x1b[?25l")
+	go d.readKeys()
+	go d.run()
+	return nil
+}
+
+// Stop restores the terminal and the output of the bot.
+func (d *Dashboard) Stop() {
+	if d == nil || d.state == nil {
+		return
+	}
+	d.stop.Do(func() {
+		close(d.done)
+		d.mu.Lock()
+		defer d.mu.Unlock()
+
+		fmt.Fprint(d.terminal, "\x1b[?25h\x1b[?1049l")
+		if err := restore(int(os.Stdin.Fd()), d.state); err != nil {
+			fmt.Fprintln(d.terminal, "Unable to restore the terminal: ", err)
+		}
+		os.Stdout, os.Stderr = d.stdout, d.stderr
+		d.restore()
+		d.pipe.Close()
+		d.inspector.Close()
+	})
+}
+
+// SetServer sets the server of the session being traded, nil while the instance is
+// on standby.
+func (d *Dashboard) SetServer(s *server.NewsServer) {
+	if d == nil {
+		return
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.server = s
+	d.selected = 0
+}
+
+// run refreshes and draws the dashboard until it is stopped.
+func (d *Dashboard) run() {
+	ticker := time.NewTicker(d.cfg.Refresh)
+	defer ticker.Stop()
+
+	for {
+		d.refresh()
+		d.draw()
+		select {
+		case <-d.done:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// refresh reads the state of the bot. The API is reached outside the lock, so the keys
+// are handled while it refreshes.
+func (d *Dashboard) refresh() {
+	d.mu.Lock()
+	s := d.server
+	d.mu.Unlock()
+
+	ctx := context.Background()
+	snap := snapshot{Time: time.Now(), Standby: s == nil, Stops: make(map[string]float64)}
+	set := func(err error) {
+		if err != nil && snap.Err == nil {
+			snap.Err = err
+		}
+	}
+
+	var err error
+	snap.Paused, err = d.cfg.Session.Paused(ctx)
+	set(err)
+	snap.Halted, err = d.cfg.Session.Halted(ctx)
+	set(err)
+	snap.News, err = d.cfg.Session.News(ctx, session.TradingDate(time.Now()), newsCount)
+	set(err)
+	if d.cfg.Approvals != nil {
+		snap.Approvals, err = d.cfg.Approvals.Pending(ctx)
+		set(err)
+	}
+	for _, queue := range []string{worker.QueueCritical, worker.QueueDefault} {
+		info, err := d.inspector.GetQueueInfo(queue)
+		if err != nil {
+			continue
+		}
+		snap.Pending += info.Pending
+		snap.Active += info.Active
+		snap.Scheduled += info.Scheduled
+	}
+
+	if s != nil {
+		snap.Starting, snap.Gain = s.Options.StartingValue, s.Options.Gain
+		snap.HighLimit, snap.LowLimit = s.Profile.HighLimit, s.Profile.LowLimit
+		snap.Equity, err = s.AlpacaClient.GetEquity()
+		set(err)
+		snap.Holdings, err = s.AlpacaClient.GetHoldings()
+		set(err)
+		sort.Slice(snap.Holdings, func(i, j int) bool { return snap.Holdings[i].Symbol < snap.Holdings[j].Symbol })
+
+		stops, err := s.AlpacaClient.GetStopOrders()
+		set(err)
+		soft_stops, err := s.Session.SoftStops(ctx)
+		set(err)
+		for _, stop := range append(stops, soft_stops...) {
+			snap.Stops[stop.Symbol] = stop.Price
+		}
+	}
+
+	d.mu.Lock()
+	d.snapshot = snap
+	d.selected = min(d.selected, max(len(snap.Holdings)-1, 0))
+	d.mu.Unlock()
+}
+
+// readKeys handles the keys pressed until the dashboard is stopped.
+func (d *Dashboard) readKeys() {
+	buf := make([]byte, 8)
+	for {
+		n, err := os.Stdin.Read(buf)
+		if err != nil {
+			return
+		}
+		select {
+		case <-d.done:
+			return
+		default:
+		}
+		d.handleKey(string(buf[:n]))
+		d.draw()
+	}
+}
+
+// handleKey handles a key. Flattening and closing a symbol ask for a confirmation first.
+func (d *Dashboard) handleKey(key string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.confirm != "" {
+		action := d.confirm
+		d.confirm = ""
+		if key == "y" || key == "Y" {
+			d.message = "running: " + action
+			go d.execute(action)
+		} else {
+			d.message = "cancelled: " + action
+		}
+		return
+	}
+
+	switch key {
+	case "p", "P":
+		paused := !d.snapshot.Paused
+		if err := d.cfg.Session.SetPaused(context.Background(), paused); err != nil {
+			d.message = err.Error()
+			return
+		}
+		d.snapshot.Paused = paused
+		d.message = "trading resumed"
+		if paused {
+			d.message = "trading paused, the news are skipped"
+		}
+	case "f", "F":
+		if d.server == nil {
+			d.message = "on standby, there is nothing to flatten"
+			return
+		}
+		d.confirm = "flatten"
+		d.message = "flatten every position? (y/n)"
+	case "c", "C":
+		if d.server == nil || len(d.snapshot.Holdings) == 0 {
+			d.message = "there is no position to close"
+			return
+		}
+		symbol := d.snapshot.Holdings[d.selected].Symbol
+		d.confirm = "close " + symbol
+		d.message = fmt.Sprintf("close the %s position? (y/n)", symbol)
+	case "k", "\x1b[A":
+		d.selected = max(d.selected-1, 0)
+	case "j", "\x1b[B":
+		d.selected = min(d.selected+1, max(len(d.snapshot.Holdings)-1, 0))
+	case "q", "Q":
+		// Quitting shuts the bot down like Ctrl+C, which raw mode still delivers.
+		if process, err := os.FindProcess(os.Getpid()); err == nil {
+			process.Signal(os.Interrupt)
+		}
+	}
+}
+
+// execute runs a confirmed action and shows its result.
+func (d *Dashboard) execute(action string) {
+	d.mu.Lock()
+	s := d.server
+	d.mu.Unlock()
+
+	var err error
+	switch {
+	case s == nil:
+		err = fmt.Errorf("the instance is on standby")
+	case action == "flatten":
+		err = s.Flatten(context.Background())
+	default:
+		err = closeSymbol(s, action[len("close "):])
+	}
+
+	result := "done: " + action
+	if err != nil {
+		result = fmt.Sprintf("failed: %s: %s", action, err)
+		log.Error().Err(err).Str("action", action).Msg("dashboard action failed")
+	}
+	d.mu.Lock()
+	d.message = result
+	d.mu.Unlock()
+	d.refresh()
+	d.draw()
+}
+
+// closeSymbol closes the position of the symbol, once no signal of it is being handled.
+func closeSymbol(s *server.NewsServer, symbol string) error {
+	ctx := context.Background()
+	lock, err := s.Session.LockSymbol(ctx, symbol)
+	if err != nil {
+		return err
+	}
+	if lock == "" {
+		return fmt.Errorf("a signal of %s is being handled, try again", symbol)
+	}
+	defer func() {
+		if err := s.Session.UnlockSymbol(ctx, symbol, lock); err != nil {
+			log.Error().Err(err).Str(logger.Symbol, symbol).Msg("failed to unlock the symbol")
+		}
+	}()
+
+	if err := s.AlpacaClient.ClosePosition(symbol); err != nil {
+		return err
+	}
+	if s.Positions != nil {
+		return s.Positions.Close(ctx, symbol)
+	}
+	return nil
+}
@@ -0,0 +1,68 @@
+// Package dashboard draws the live state of the bot in the terminal and lets the user
+// pause the trading, flatten the positions or close a symbol with a key.
+package dashboard
+
+import (
+	"bufio"
+	"io"
+	"strings"
+	"sync"
+)
+
+// ring keeps the latest lines written to it.
+type ring struct {
+	mu    sync.Mutex
+	lines []string
+	size  int
+}
+
+// newRing returns a ring that keeps the given amount of lines.
+func newRing(size int) *ring {
+	return &ring{
+		size: size,
+	}
+}
+
+// add appends a line, dropping the oldest one when the ring is full.
+func (r *ring) add(line string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.lines = append(r.lines, line)
+	if len(r.lines) > r.size {
+		r.lines = r.lines[len(r.lines)-r.size:]
+	}
+}
+
+// last returns up to count of the latest lines, the oldest first.
+func (r *ring) last(count int) []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if count <= 0 {
+		return nil
+	}
+	start := max(len(r.lines)-count, 0)
+	return append([]string(nil), r.lines[start:]...)
+}
+
+// capture reads the output of the bot line by line into the output ring, and the lines
+// that look like errors into the errors ring too, until the reader is closed.
+func capture(reader io.Reader, output *ring, errors *ring) {
+	scanner := bufio.NewScanner(reader)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if line == "" {
+			continue
+		}
+		output.add(line)
+		if isError(line) {
+			errors.add(line)
+		}
+	}
+}
+
+// isError returns true if the line of output reports an error.
+func isError(line string) bool {
+	lower := strings.ToLower(line)
+	return strings.Contains(lower, " err ") || strings.Contains(lower, "error") ||
+		strings.Contains(lower, "unable") || strings.Contains(lower, "failed")
+}
@@ -0,0 +1,189 @@
+// Package dashboard draws the live state of the bot in the terminal and lets the user
+// pause the trading, flatten the positions or close a symbol with a key.
+package dashboard
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	reset   = "\x1b[0m"
+	bold    = "\x1b[1m"
+	reverse = "\x1b[7m"
+	red     = "\x1b[31m"
+	green   = "\x1b[32m"
+	yellow  = "\x1b[33m"
+	dim     = "\x1b[2m"
+
+	footer = "[p] pause/resume  [f] flatten  [↑/↓] select  [c] close selected  [q] quit dashboard"
+)
+
+// screen builds the lines of a frame, truncated to the width of the terminal.
+type screen struct {
+	width int
+	lines []string
+}
+
+// add adds a line with the given style, the text is cut at the width of the terminal.
+func (s *screen) add(style string, format string, args ...interface{}) {
+	text := []rune(fmt.Sprintf(format, args...))
+	if len(text) > s.width {
+		text = text[:s.width]
+	}
+	if style == "" {
+		s.lines = append(s.lines, string(text))
+		return
+	}
+	s.lines = append(s.lines, style+string(text)+reset)
+}
+
+// draw draws a frame of the dashboard.
+func (d *Dashboard) draw() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	select {
+	case <-d.done:
+		return
+	default:
+	}
+
+	width, height, err := size(int(os.Stdin.Fd()))
+	if err != nil || width <= 0 || height <= 0 {
+		width, height = 100, 40
+	}
+	s := &screen{width: width}
+	snap := d.snapshot
+
+	d.header(s, snap)
+	d.positions(s, snap)
+	d.news(s, snap)
+	if len(snap.Approvals) > 0 {
+		s.add(bold, "APPROVALS")
+		for _, decision := range snap.Approvals {
+			s.add(yellow, "  %s", decision)
+		}
+		s.add("", "")
+	}
+
+	errors := d.errors.last(5)
+	s.add(bold, "ERRORS")
+	if len(errors) == 0 {
+		s.add(dim, "  none")
+	}
+	for _, line := range errors {
+		s.add(red, "  %s", line)
+	}
+	s.add("", "")
+
+	// The output fills the rest of the screen, above the footer.
+	s.add(bold, "OUTPUT")
+	for _, line := range d.output.last(height - len(s.lines) - 2) {
+		s.add(dim, "  %s", line)
+	}
+	for len(s.lines) < height-1 {
+		s.add("", "")
+	}
+	if d.message != "" {
+		s.add(yellow, "%s   %s", footer, d.message)
+	} else {
+		s.add(reverse, "%s", footer)
+	}
+
+	if len(s.lines) > height {
+		s.lines = append(s.lines[:height-1], s.lines[len(s.lines)-1])
+	}
+	fmt.Fprint(d.terminal, "\x1b[H\x1b[2J"+strings.Join(s.lines, "\r\n"))
+}
+
+// header draws the state of the trading and the equity against the gain target.
+func (d *Dashboard) header(s *screen, snap snapshot) {
+	status, style := "RUNNING", green
+	switch {
+	case snap.Halted != "":
+		status, style = "HALTED: "+snap.Halted, red
+	case snap.Paused:
+		status, style = "PAUSED", yellow
+	case snap.Standby:
+		status, style = "STANDBY", dim
+	}
+	s.add(bold, "TradingBotCli  %s", snap.Time.Format(time.TimeOnly))
+	s.add(style, "%s", status)
+
+	if !snap.Standby {
+		pnl := snap.Equity - snap.Starting
+		pnl_style := green
+		if pnl < 0 {
+			pnl_style = red
+		}
+		percent := 0.0
+		if snap.Starting > 0 {
+			percent = pnl / snap.Starting * 100
+		}
+		s.add(pnl_style, "Equity %.2f  start %.2f  P&L %+.2f (%+.2f%%)", snap.Equity, snap.Starting, pnl, percent)
+		s.add("", "Target %.2f  %s", snap.Starting+snap.Gain, progress(pnl, snap.Gain, 30))
+	}
+	s.add("", "Tasks: %d pending, %d active, %d scheduled   Approvals: %d waiting",
+		snap.Pending, snap.Active, snap.Scheduled, len(snap.Approvals))
+	if snap.Err != nil {
+		s.add(red, "Refresh: %s", snap.Err)
+	}
+	s.add("", "")
+}
+
+// positions draws the open positions with their unrealized P&L and their stops.
+func (d *Dashboard) positions(s *screen, snap snapshot) {
+	s.add(bold, "POSITIONS")
+	s.add(dim, "  %-8s %10s %10s %10s %10s %8s %10s", "SYMBOL", "QTY", "ENTRY", "PRICE", "P&L", "P&L%", "STOP")
+	if len(snap.Holdings) == 0 {
+		s.add(dim, "  none")
+	}
+	for i, holding := range snap.Holdings {
+		stop := "-"
+		if price, exists := snap.Stops[holding.Symbol]; exists {
+			stop = fmt.Sprintf("%.2f", price)
+		}
+		style := green
+		if holding.UnrealizedPL < 0 {
+			style = red
+		}
+		cursor := " "
+		if i == d.selected {
+			cursor, style = ">", style+reverse
+		}
+		s.add(style, "%s %-8s %10g %10.2f %10.2f %+10.2f %+7.2f%% %10s", cursor, holding.Symbol, holding.Qty,
+			holding.AvgEntryPrice, holding.CurrentPrice, holding.UnrealizedPL, holding.UnrealizedPLPercent*100, stop)
+	}
+	s.add("", "")
+}
+
+// news draws the latest headlines with their scores, colored by the limits of the profile.
+func (d *Dashboard) news(s *screen, snap snapshot) {
+	s.add(bold, "NEWS")
+	if len(snap.News) == 0 {
+		s.add(dim, "  none")
+	}
+	for _, news := range snap.News {
+		style := ""
+		switch {
+		case snap.HighLimit > 0 && news.Score >= snap.HighLimit:
+			style = green
+		case news.Score > 0 && news.Score <= snap.LowLimit:
+			style = red
+		}
+		s.add(style, "  %s %-12s %3d  %s", news.Time.Format(time.TimeOnly), strings.Join(news.Symbols, ","), news.Score, news.Headline)
+	}
+	s.add("", "")
+}
+
+// progress returns a bar of the given width with the share of the gain reached.
+func progress(pnl float64, gain float64, width int) string {
+	share := 0.0
+	if gain > 0 {
+		share = min(max(pnl/gain, 0), 1)
+	}
+	filled := int(share * float64(width))
+	return fmt.Sprintf("[%s%s] %.0f%%", strings.Repeat("#", filled), strings.Repeat("-", width-filled), share*100)
+}
@@ -0,0 +1,45 @@
+//go:build linux
+
+// Package dashboard draws the live state of the bot in the terminal and lets the user
+// pause the trading, flatten the positions or close a symbol with a key.
+package dashboard
+
+import (
+	"golang.org/x/sys/unix"
+)
+
+// termState is the state of the terminal.
+type termState = unix.Termios
+
+// makeRaw turns off the line buffering and the echo of the terminal, so every key is read
+// as soon as it is pressed. The signals are kept, so ctrl+c still stops the bot. It returns
+// the previous state of the terminal.
+func makeRaw(fd int) (*termState, error) {
+	termios, err := unix.IoctlGetTermios(fd, unix.TCGETS)
+	if err != nil {
+		return nil, err
+	}
+	previous := *termios
+
+	termios.Lflag &^= unix.ICANON | unix.ECHO
+	termios.Cc[unix.VMIN] = 1
+	termios.Cc[unix.VTIME] = 0
+	if err := unix.IoctlSetTermios(fd, unix.TCSETS, termios); err != nil {
+		return nil, err
+	}
+	return &previous, nil
+}
+
+// restore sets the terminal back to the given state.
+func restore(fd int, state *termState) error {
+	return unix.IoctlSetTermios(fd, unix.TCSETS, state)
+}
+
+// size returns the width and the height of the terminal.
+func size(fd int) (int, int, error) {
+	window, err := unix.IoctlGetWinsize(fd, unix.TIOCGWINSZ)
+	if err != nil {
+		return 0, 0, err
+	}
+	return int(window.Col), int(window.Row), nil
+}
@@ -0,0 +1,27 @@
+//go:build !linux
+
+// Package dashboard draws the live state of the bot in the terminal and lets the user
+// pause the trading, flatten the positions or close a symbol with a key.
+package dashboard
+
+import "errors"
+
+// termState is not used outside linux.
+type termState struct{}
+
+var errUnsupported = errors.New("the dashboard is only supported on linux")
+
+// makeRaw returns an error, the raw mode is only supported on linux.
+func makeRaw(fd int) (*termState, error) {
+	return nil, errUnsupported
+}
+
+// restore does nothing outside linux.
+func restore(fd int, state *termState) error {
+	return errUnsupported
+}
+
+// size returns an error outside linux.
+func size(fd int) (int, int, error) {
+	return 0, 0, errUnsupported
+}
@@ -5,7 +5,10 @@ go 1.21.3
 require (
 	github.com/alpacahq/alpaca-trade-api-go/v3 v3.2.2
 	github.com/hibiken/asynq v0.24.1
+	github.com/redis/go-redis/v9 v9.3.0
 	golang.org/x/net v0.19.0
+	golang.org/x/sys v0.15.0
+	golang.org/x/time v0.5.0
 )
 
 require (
@@ -15,11 +18,8 @@ require (
 	github.com/google/uuid v1.4.0 // indirect
 	github.com/mattn/go-colorable v0.1.13 // indirect
 	github.com/mattn/go-isatty v0.0.20 // indirect
-	github.com/redis/go-redis/v9 v9.3.0 // indirect
 	github.com/robfig/cron/v3 v3.0.1 // indirect
 	github.com/spf13/cast v1.6.0 // indirect
-	golang.org/x/sys v0.15.0 // indirect
-	golang.org/x/time v0.5.0 // indirect
 	google.golang.org/protobuf v1.31.0 // indirect
 )
 
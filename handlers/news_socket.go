@@ -7,12 +7,19 @@ import (
 	"fmt"
 	"io"
 	"net"
+	"slices"
 	"time"
 
+	alpacaapi "github.com/alpacahq/alpaca-trade-api-go/v3/alpaca"
 	"github.com/hibiken/asynq"
+	"github.com/jmvdr-iscte/TradingBotCli/alpaca"
+	"github.com/jmvdr-iscte/TradingBotCli/logger"
 	"github.com/jmvdr-iscte/TradingBotCli/models"
+	"github.com/jmvdr-iscte/TradingBotCli/notify"
 	"github.com/jmvdr-iscte/TradingBotCli/server"
+	"github.com/jmvdr-iscte/TradingBotCli/session"
 	"github.com/jmvdr-iscte/TradingBotCli/worker"
+	"github.com/rs/zerolog/log"
 	"golang.org/x/net/websocket"
 )
 
@@ -20,7 +27,7 @@ import (
 // tasks to redis, in order to deal with the buying and selling
 // opperations.
 func HandleWS(ws *websocket.Conn, s *server.NewsServer) {
-	fmt.Println("new incoming connection from client: ", ws.RemoteAddr())
+	log.Info().Str("remote", ws.RemoteAddr().String()).Msg("new incoming connection from client")
 	options := []asynq.Option{
 		asynq.ProcessIn(1 * time.Second),
 		asynq.Queue(worker.QueueCritical),
@@ -31,7 +38,11 @@ func HandleWS(ws *websocket.Conn, s *server.NewsServer) {
 	s.Mu.Unlock()
 
 	stopChan := make(chan bool)
-	go monitorData(s, stopChan)
+	go func() {
+		if err := monitorData(s, stopChan); err != nil {
+			s.Notifier.Notify(notify.Error, "", fmt.Sprintf("the monitor stopped: %s", err))
+		}
+	}()
 	if err := readData(ws, s, options, stopChan); err == io.EOF {
 		s.Mu.Lock()
 		delete(s.Conns, ws)
@@ -39,10 +50,11 @@ func HandleWS(ws *websocket.Conn, s *server.NewsServer) {
 		return
 
 	} else if err != nil {
-		fmt.Println("Error handling the websocket: %w", err)
+		log.Error().Err(err).Msg("error handling the websocket")
+		s.Notifier.Notify(notify.Error, "", fmt.Sprintf("the news socket failed: %s", err))
 		return
 	}
-	fmt.Println("websocket sucessfully closed")
+	log.Info().Msg("websocket successfully closed")
 	s.Mu.Lock()
 	delete(s.Conns, ws)
 	s.Mu.Unlock()
@@ -71,7 +83,7 @@ func readData(ws *websocket.Conn, s *server.NewsServer, opts []asynq.Option, sto
 				} else if err == io.EOF {
 					break
 				}
-				fmt.Println("Read error: ", err)
+				log.Error().Err(err).Msg("read error")
 				return err
 			}
 
@@ -82,20 +94,22 @@ func readData(ws *websocket.Conn, s *server.NewsServer, opts []asynq.Option, sto
 				if err == io.ErrUnexpectedEOF {
 					continue
 				}
-				fmt.Println("Error when getting the news: ", err)
+				log.Error().Err(err).Msg("error when getting the news")
 				continue
 			}
 
 			for _, message := range messages {
+				log.Debug().Int64(logger.NewsID, message.ID).Strs("symbols", message.Symbols).Str("headline", message.Headline).
+					Msg("received news")
 				if len(message.Headline) != 0 {
 					message.Risk = s.Options.Risk
+					message.Fence = s.Fence
 					err = s.Task_distributor.DistributeTaskProcessOrder(context.Background(), &message, opts...)
 					if err != nil {
 						return fmt.Errorf("unable to distribute task %w", err)
 					}
 				}
 			}
-			fmt.Println("Received message: ", string(message_buffer))
 			message_buffer = nil
 		}
 	}
@@ -103,12 +117,26 @@ func readData(ws *websocket.Conn, s *server.NewsServer, opts []asynq.Option, sto
 
 // monitorData returns an error if there was an error connecting to the api.
 // It monitors the whole system in order to be able to correctly close
-// positions and shutdown the system.
+// positions and shutdown the system. It stops as soon as the server shuts down.
 func monitorData(s *server.NewsServer, stopChan chan<- bool) error {
 	ticker := time.NewTicker(30 * time.Second)
 	defer ticker.Stop()
+	stops_checked := time.Now()
 
-	for range ticker.C {
+	for {
+		select {
+		case <-s.Done():
+			return nil
+		case <-ticker.C:
+		}
+
+		restricted, err := s.CheckRestrictions()
+		if err != nil {
+			log.Error().Err(err).Msg("unable to check the account restrictions")
+		} else if restricted {
+			stopChan <- true
+			return nil
+		}
 
 		haveTrades, err := s.AlpacaClient.HaveTrades()
 		if err != nil {
@@ -122,18 +150,44 @@ func monitorData(s *server.NewsServer, stopChan chan<- bool) error {
 			return err
 		}
 
-		can_close_positions, err := s.AlpacaClient.CanClosePositions()
+		if err := s.RecordRealizedPnL(current_equity); err != nil {
+			log.Error().Err(err).Msg("unable to record the realized P&L")
+		}
+
+		if err := s.AlpacaClient.ReconcileCash(); err != nil {
+			log.Error().Err(err).Msg("unable to reconcile the cash ledger")
+		}
+
+		if err := reconcileDayTrades(s); err != nil {
+			log.Error().Err(err).Msg("unable to reconcile the day trades")
+		}
+
+		if stops_checked, err = notifyStops(s, stops_checked); err != nil {
+			log.Error().Err(err).Msg("unable to check the triggered stops")
+		}
+
+		if err := manageExits(s); err != nil {
+			log.Error().Err(err).Msg("unable to check the exits")
+		}
+
+		if s.AlpacaClient.ExtendedHours() {
+			if err := watchSoftStops(s); err != nil {
+				log.Error().Err(err).Msg("unable to watch the soft stops")
+			}
+		}
+
+		can_close_positions, err := closeSession(s)
 		if err != nil {
 			stopChan <- true
 			return err
 		}
 
-		fmt.Printf("current equity %f\n", current_equity)
-		fmt.Printf("possible gainz %f\n", s.Options.StartingValue+s.Options.Gain)
+		log.Info().Float64("equity", current_equity).Float64("target", s.Options.StartingValue+s.Options.Gain).Msg("checked the equity")
 		if current_equity >= s.Options.StartingValue+s.Options.Gain {
 			result := current_equity - s.Options.StartingValue
-			fmt.Printf("you gained %f\n:", result)
-			err = s.AlpacaClient.ClosePositions()
+			log.Info().Float64("gain", result).Msg("gain target reached, closing the positions")
+			s.Notifier.Notify(notify.GainTarget, "", fmt.Sprintf("gained %.2f, closing the positions", result))
+			err = s.Flatten(context.Background())
 			if err != nil {
 				stopChan <- true
 				return err
@@ -142,7 +196,17 @@ func monitorData(s *server.NewsServer, stopChan chan<- bool) error {
 			return nil
 		}
 
-		if !haveTrades {
+		if s.MaxLoss > 0 && current_equity <= s.Options.StartingValue-s.MaxLoss {
+			result := s.Options.StartingValue - current_equity
+			log.Warn().Float64("loss", result).Msg("max daily loss reached, closing the positions")
+			s.Notifier.Notify(notify.MaxLoss, "", fmt.Sprintf("lost %.2f, closing the positions", result))
+			err = s.Flatten(context.Background())
+			stopChan <- true
+			return err
+		}
+
+		// With the ledger the day trades are budgeted, so the session goes on without them.
+		if !haveTrades && s.Ledger == nil {
 			stopChan <- true
 			return nil
 		}
@@ -152,5 +216,214 @@ func monitorData(s *server.NewsServer, stopChan chan<- bool) error {
 			return nil
 		}
 	}
+}
+
+// closeSession returns true if the session is ending. The positions are closed, or trimmed
+// to the overnight limits when the risk profile holds them overnight.
+func closeSession(s *server.NewsServer) (bool, error) {
+	if !s.Profile.Overnight.Enabled && s.Ledger == nil {
+		return s.AlpacaClient.CanClosePositions()
+	}
+
+	closing, err := s.AlpacaClient.IsClosing()
+	if err != nil || !closing {
+		return false, err
+	}
+	if !s.Profile.Overnight.Enabled {
+		log.Info().Msg("15 minutes left until the end of the session, closing the positions")
+		return true, s.Flatten(context.Background())
+	}
+	log.Info().Msg("15 minutes left until the end of the session, preparing the positions for the night")
+	return true, s.AlpacaClient.CarryOvernight(s.Profile)
+}
+
+// reconcileGrace is how long after its last trade a symbol is left out of the day trade
+// reconciliation, a position opened moments ago may not be in the holdings yet.
+const reconcileGrace = 2 * time.Minute
+
+// reconcileDayTrades returns an error if it was not able to update the day trade ledger
+// with the positions closed at the broker, like the ones hit by a stop loss. The symbols
+// traded within the grace period are counted as held.
+func reconcileDayTrades(s *server.NewsServer) error {
+	if s.Ledger == nil {
+		return nil
+	}
+	ctx := context.Background()
+	date := session.TradingDate(time.Now())
+
+	holdings, err := s.AlpacaClient.GetHoldings()
+	if err != nil {
+		return err
+	}
+	last_trades, err := s.Session.LastTrades(ctx, date)
+	if err != nil {
+		return err
+	}
+	held := make([]string, 0, len(holdings)+len(last_trades))
+	for _, holding := range holdings {
+		held = append(held, holding.Symbol)
+	}
+	for symbol, at := range last_trades {
+		if time.Since(at) < reconcileGrace {
+			held = append(held, symbol)
+		}
+	}
+	return s.Ledger.Reconcile(ctx, date, held)
+}
+
+// notifyStops returns the time the stops were checked until. The stop orders filled since
+// the last check are notified, it only reaches the API when there is a notifier. The orders
+// are filtered by their submission, so they are fetched from the start of the session and
+// the fills are filtered by their time.
+func notifyStops(s *server.NewsServer, since time.Time) (time.Time, error) {
+	if s.Notifier == nil {
+		return since, nil
+	}
+	now := time.Now()
+	orders, err := s.AlpacaClient.GetFilledOrders(s.StartedAt)
+	if err != nil {
+		return since, err
+	}
+	for _, order := range orders {
+		if order.Type != alpacaapi.Stop && order.Type != alpacaapi.TrailingStop {
+			continue
+		}
+		if !order.FilledAt.After(since) || order.FilledAt.After(now) {
+			continue
+		}
+		s.Notifier.Notify(notify.StopTriggered, order.Symbol,
+			fmt.Sprintf("%s %s filled at %s", order.Side, order.FilledQty, order.FilledAvgPrice))
+	}
+	return now, nil
+}
+
+// manageExits returns an error if it was not able to check the exit rules of the positions.
+// The positions held for too long or that reached the profit target are closed, and the
+// entries of the positions that are no longer open are deleted.
+func manageExits(s *server.NewsServer) error {
+	if s.Positions == nil {
+		return nil
+	}
+	ctx := context.Background()
+
+	entries, err := s.Positions.Entries(ctx)
+	if err != nil {
+		return err
+	}
+	holdings, err := s.AlpacaClient.GetHoldings()
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		index := slices.IndexFunc(holdings, func(h models.Holding) bool { return h.Symbol == entry.Symbol })
+		if index < 0 {
+			if err := s.Positions.Close(ctx, entry.Symbol); err != nil {
+				return err
+			}
+			continue
+		}
+
+		reason, exit := s.Positions.Exits.Check(entry, time.Now(), holdings[index].UnrealizedPLPercent)
+		if !exit {
+			continue
+		}
+		// A signal of the symbol being handled goes first, the exit is checked again on the next tick.
+		if err := s.AlpacaClient.CheckDayTradeClose(ctx, s.Ledger, entry.Symbol); err != nil {
+			log.Warn().Err(err).Str(logger.Symbol, entry.Symbol).Msg("not closing the position")
+			continue
+		}
+		lock, err := s.Session.LockSymbol(ctx, entry.Symbol)
+		if err != nil || lock == "" {
+			continue
+		}
+		log.Info().Str(logger.Symbol, entry.Symbol).Int64(logger.NewsID, entry.NewsID).Str("side", entry.Side).
+			Str("headline", entry.Headline).Str("reason", reason).Msg("closing the position")
+		err = s.AlpacaClient.ClosePosition(entry.Symbol)
+		if err == nil {
+			err = s.Positions.Close(ctx, entry.Symbol)
+		}
+		if unlock_err := s.Session.UnlockSymbol(ctx, entry.Symbol, lock); unlock_err != nil {
+			log.Error().Err(unlock_err).Str(logger.Symbol, entry.Symbol).Msg("unable to unlock the symbol")
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// watchSoftStops returns an error if it was not able to check the stops that the broker
+// does not trigger outside the regular session. In the last minutes of the regular session
+// the stop orders are saved as soft stops, outside of it the positions whose soft stop is
+// hit are closed, and once the regular session is back the soft stops become stop orders.
+func watchSoftStops(s *server.NewsServer) error {
+	ctx := context.Background()
+	market_session, err := s.AlpacaClient.GetMarketSession()
+	if err != nil {
+		return err
+	}
+
+	if market_session == alpaca.SessionRegular {
+		_, closing, err := s.AlpacaClient.GetSessionHours()
+		if err != nil {
+			return err
+		}
+		if time.Until(closing) < 15*time.Minute {
+			stops, err := s.AlpacaClient.GetStopOrders()
+			if err != nil {
+				return err
+			}
+			for _, stop := range stops {
+				if err := s.Session.SetSoftStop(ctx, stop); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+	}
+
+	stops, err := s.Session.SoftStops(ctx)
+	if err != nil {
+		return err
+	}
+	for _, stop := range stops {
+		qty, err := s.AlpacaClient.GetPositionQty(stop.Symbol)
+		if err != nil {
+			return err
+		}
+		if qty.IsZero() {
+			if err := s.Session.DeleteSoftStop(ctx, stop.Symbol); err != nil {
+				return err
+			}
+			continue
+		}
+
+		switch {
+		case market_session == alpaca.SessionRegular:
+			if err := s.AlpacaClient.PlaceStop(stop); err != nil {
+				return err
+			}
+		case market_session.Extended():
+			price, err := s.AlpacaClient.GetPrice(stop.Symbol)
+			if err != nil {
+				return err
+			}
+			if !stop.Triggered(price) {
+				continue
+			}
+			log.Warn().Str(logger.Symbol, stop.Symbol).Float64("stop_price", stop.Price).Float64("price", price).
+				Msg("soft stop hit, closing the position")
+			s.Notifier.Notify(notify.StopTriggered, stop.Symbol, fmt.Sprintf("soft stop at %.2f hit at %.2f", stop.Price, price))
+			if err := s.AlpacaClient.ClosePosition(stop.Symbol); err != nil {
+				return err
+			}
+		default:
+			continue
+		}
+		if err := s.Session.DeleteSoftStop(ctx, stop.Symbol); err != nil {
+			return err
+		}
+	}
 	return nil
 }
@@ -0,0 +1,55 @@
+// Package initialize serves to initialize the configs.
+package initialize
+
+import (
+	"os"
+	"strconv"
+	"time"
+)
+
+// ApprovalConfig is the config of the approval mode.
+type ApprovalConfig struct {
+	Enabled  bool
+	Timeout  time.Duration
+	MinValue float64
+	Addr     string
+	Token    string
+}
+
+// LoadApprovalConfigs loads the approval configs with the values from .env.
+func LoadApprovalConfigs() *ApprovalConfig {
+	cfg := &ApprovalConfig{
+		Enabled:  false,
+		Timeout:  5 * time.Minute,
+		MinValue: 0,
+		Addr:     "127.0.0.1:3000",
+		Token:    "",
+	}
+
+	if enabled, exists := os.LookupEnv("APPROVAL_MODE"); exists {
+		if value, err := strconv.ParseBool(enabled); err == nil {
+			cfg.Enabled = value
+		}
+	}
+
+	if timeout, exists := os.LookupEnv("APPROVAL_TIMEOUT"); exists {
+		if value, err := time.ParseDuration(timeout); err == nil && value > 0 {
+			cfg.Timeout = value
+		}
+	}
+
+	if min_value, exists := os.LookupEnv("APPROVAL_MIN_VALUE"); exists {
+		if value, err := strconv.ParseFloat(min_value, 64); err == nil {
+			cfg.MinValue = value
+		}
+	}
+
+	if addr, exists := os.LookupEnv("APPROVAL_ADDR"); exists {
+		cfg.Addr = addr
+	}
+
+	if token, exists := os.LookupEnv("APPROVAL_TOKEN"); exists {
+		cfg.Token = token
+	}
+	return cfg
+}
@@ -0,0 +1,26 @@
+// Package initialize serves to initialize the configs.
+package initialize
+
+import (
+	"os"
+	"strconv"
+)
+
+// CashConfig is the config of the cash account mode.
+type CashConfig struct {
+	Account bool
+}
+
+// LoadCashConfigs loads the cash account configs with the values from .env.
+func LoadCashConfigs() *CashConfig {
+	cfg := &CashConfig{
+		Account: false,
+	}
+
+	if account, exists := os.LookupEnv("CASH_ACCOUNT"); exists {
+		if value, err := strconv.ParseBool(account); err == nil {
+			cfg.Account = value
+		}
+	}
+	return cfg
+}
@@ -0,0 +1,23 @@
+// Package initialize serves to initialize the configs.
+package initialize
+
+import (
+	"os"
+)
+
+// ComplianceConfig is the config of the pre-trade compliance rules.
+type ComplianceConfig struct {
+	RulesFile string
+}
+
+// LoadComplianceConfigs loads the compliance configs with the values from .env.
+func LoadComplianceConfigs() *ComplianceConfig {
+	cfg := &ComplianceConfig{
+		RulesFile: "",
+	}
+
+	if file, exists := os.LookupEnv("COMPLIANCE_RULES_FILE"); exists {
+		cfg.RulesFile = file
+	}
+	return cfg
+}
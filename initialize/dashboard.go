@@ -0,0 +1,35 @@
+// Package initialize serves to initialize the configs.
+package initialize
+
+import (
+	"os"
+	"strconv"
+	"time"
+)
+
+// DashboardConfig is the config of the terminal dashboard.
+type DashboardConfig struct {
+	Enabled bool
+	Refresh time.Duration
+}
+
+// LoadDashboardConfigs loads the dashboard configs with the values from .env.
+func LoadDashboardConfigs() *DashboardConfig {
+	cfg := &DashboardConfig{
+		Enabled: false,
+		Refresh: 2 * time.Second,
+	}
+
+	if enabled, exists := os.LookupEnv("DASHBOARD"); exists {
+		if value, err := strconv.ParseBool(enabled); err == nil {
+			cfg.Enabled = value
+		}
+	}
+
+	if refresh, exists := os.LookupEnv("DASHBOARD_REFRESH"); exists {
+		if value, err := time.ParseDuration(refresh); err == nil {
+			cfg.Refresh = value
+		}
+	}
+	return cfg
+}
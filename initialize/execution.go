@@ -0,0 +1,106 @@
+// Package initialize serves to initialize the configs.
+package initialize
+
+import (
+	"os"
+	"strconv"
+	"time"
+)
+
+const (
+	// WideSpreadReject refuses the orders when the spread is too wide.
+	WideSpreadReject = "reject"
+	// WideSpreadLimit sends a marketable limit order when the spread is too wide.
+	WideSpreadLimit = "limit"
+
+	// OrderMarket sends market orders.
+	OrderMarket = "market"
+	// OrderLimit sends limit orders that are repriced until they fill or reach the max slippage.
+	OrderLimit = "limit"
+
+	// LimitMid prices the limit orders at the mid price.
+	LimitMid = "mid"
+	// LimitTouch prices the buys at the bid and the sells at the ask.
+	LimitTouch = "touch"
+	// LimitOffset prices the limit orders at an offset from the mid price.
+	LimitOffset = "offset"
+)
+
+// ExecutionConfig is the config of the order execution and of the spread and
+// slippage guard. A zero MaxSpreadBps or MaxQuoteAge disables the check.
+type ExecutionConfig struct {
+	MaxSpreadBps   float64
+	MaxQuoteAge    time.Duration
+	WideSpread     string
+	MaxSlippageBps float64
+	OrderType      string
+	LimitPrice     string
+	LimitOffsetBps float64
+	RepriceAfter   time.Duration
+	RepriceStepBps float64
+}
+
+// LoadExecutionConfigs loads the execution configs with the values from .env.
+func LoadExecutionConfigs() *ExecutionConfig {
+	cfg := &ExecutionConfig{
+		MaxSpreadBps:   0,
+		MaxQuoteAge:    0,
+		WideSpread:     WideSpreadReject,
+		MaxSlippageBps: 10,
+		OrderType:      OrderMarket,
+		LimitPrice:     LimitMid,
+		LimitOffsetBps: 0,
+		RepriceAfter:   5 * time.Second,
+		RepriceStepBps: 5,
+	}
+
+	if spread, exists := os.LookupEnv("EXECUTION_MAX_SPREAD_BPS"); exists {
+		if value, err := strconv.ParseFloat(spread, 64); err == nil && value >= 0 {
+			cfg.MaxSpreadBps = value
+		}
+	}
+
+	if age, exists := os.LookupEnv("EXECUTION_MAX_QUOTE_AGE"); exists {
+		if value, err := time.ParseDuration(age); err == nil && value >= 0 {
+			cfg.MaxQuoteAge = value
+		}
+	}
+
+	if wide, exists := os.LookupEnv("EXECUTION_WIDE_SPREAD"); exists && wide == WideSpreadLimit {
+		cfg.WideSpread = WideSpreadLimit
+	}
+
+	if slippage, exists := os.LookupEnv("EXECUTION_MAX_SLIPPAGE_BPS"); exists {
+		if value, err := strconv.ParseFloat(slippage, 64); err == nil && value >= 0 {
+			cfg.MaxSlippageBps = value
+		}
+	}
+	if order_type, exists := os.LookupEnv("EXECUTION_ORDER_TYPE"); exists && order_type == OrderLimit {
+		cfg.OrderType = OrderLimit
+	}
+
+	if limit_price, exists := os.LookupEnv("EXECUTION_LIMIT_PRICE"); exists {
+		if limit_price == LimitTouch || limit_price == LimitOffset {
+			cfg.LimitPrice = limit_price
+		}
+	}
+
+	if offset, exists := os.LookupEnv("EXECUTION_LIMIT_OFFSET_BPS"); exists {
+		if value, err := strconv.ParseFloat(offset, 64); err == nil {
+			cfg.LimitOffsetBps = value
+		}
+	}
+
+	if after, exists := os.LookupEnv("EXECUTION_REPRICE_AFTER"); exists {
+		if value, err := time.ParseDuration(after); err == nil && value > 0 {
+			cfg.RepriceAfter = value
+		}
+	}
+
+	if step, exists := os.LookupEnv("EXECUTION_REPRICE_STEP_BPS"); exists {
+		if value, err := strconv.ParseFloat(step, 64); err == nil && value > 0 {
+			cfg.RepriceStepBps = value
+		}
+	}
+	return cfg
+}
@@ -0,0 +1,43 @@
+// Package initialize serves to initialize the configs.
+package initialize
+
+import (
+	"os"
+	"strconv"
+	"time"
+)
+
+// ExitConfig is the config of the exit rules of every position, the zero values are disabled.
+type ExitConfig struct {
+	MaxHold       time.Duration
+	ProfitTarget  float64
+	ReversalScore int
+}
+
+// LoadExitConfigs loads the exit configs with the values from .env.
+func LoadExitConfigs() *ExitConfig {
+	cfg := &ExitConfig{
+		MaxHold:       0,
+		ProfitTarget:  0,
+		ReversalScore: 0,
+	}
+
+	if max_hold, exists := os.LookupEnv("EXIT_MAX_HOLD"); exists {
+		if value, err := time.ParseDuration(max_hold); err == nil && value >= 0 {
+			cfg.MaxHold = value
+		}
+	}
+
+	if target, exists := os.LookupEnv("EXIT_PROFIT_TARGET"); exists {
+		if value, err := strconv.ParseFloat(target, 64); err == nil && value >= 0 {
+			cfg.ProfitTarget = value
+		}
+	}
+
+	if reversal, exists := os.LookupEnv("EXIT_REVERSAL_SCORE"); exists {
+		if value, err := strconv.Atoi(reversal); err == nil && value >= 0 && value < 50 {
+			cfg.ReversalScore = value
+		}
+	}
+	return cfg
+}
@@ -0,0 +1,51 @@
+// Package initialize serves to initialize the configs.
+package initialize
+
+import (
+	"os"
+	"strconv"
+)
+
+// ExtendedConfig is the config of the pre-market and after-hours trading. The caps
+// only apply to the orders sent outside the regular session, a zero cap is disabled.
+type ExtendedConfig struct {
+	Enabled          bool
+	SizeMultiplier   float64
+	MaxPositionValue float64
+	MaxPositions     int
+}
+
+// LoadExtendedConfigs loads the extended hours configs with the values from .env.
+func LoadExtendedConfigs() *ExtendedConfig {
+	cfg := &ExtendedConfig{
+		Enabled:          false,
+		SizeMultiplier:   0.5,
+		MaxPositionValue: 0,
+		MaxPositions:     0,
+	}
+
+	if enabled, exists := os.LookupEnv("EXTENDED_HOURS"); exists {
+		if value, err := strconv.ParseBool(enabled); err == nil {
+			cfg.Enabled = value
+		}
+	}
+
+	if multiplier, exists := os.LookupEnv("EXTENDED_SIZE_MULTIPLIER"); exists {
+		if value, err := strconv.ParseFloat(multiplier, 64); err == nil && value > 0 {
+			cfg.SizeMultiplier = value
+		}
+	}
+
+	if max_value, exists := os.LookupEnv("EXTENDED_MAX_POSITION_VALUE"); exists {
+		if value, err := strconv.ParseFloat(max_value, 64); err == nil && value >= 0 {
+			cfg.MaxPositionValue = value
+		}
+	}
+
+	if max_positions, exists := os.LookupEnv("EXTENDED_MAX_POSITIONS"); exists {
+		if value, err := strconv.Atoi(max_positions); err == nil && value >= 0 {
+			cfg.MaxPositions = value
+		}
+	}
+	return cfg
+}
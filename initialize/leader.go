@@ -0,0 +1,26 @@
+// Package initialize serves to initialize the configs.
+package initialize
+
+import (
+	"os"
+	"time"
+)
+
+// LeaderConfig is the config of the leader lease.
+type LeaderConfig struct {
+	LeaseTTL time.Duration
+}
+
+// LoadLeaderConfigs loads the leader configs with the values from .env.
+func LoadLeaderConfigs() *LeaderConfig {
+	cfg := &LeaderConfig{
+		LeaseTTL: 15 * time.Second,
+	}
+
+	if ttl, exists := os.LookupEnv("LEADER_LEASE_TTL"); exists {
+		if value, err := time.ParseDuration(ttl); err == nil && value > 0 {
+			cfg.LeaseTTL = value
+		}
+	}
+	return cfg
+}
@@ -0,0 +1,55 @@
+// Package initialize serves to initialize the configs.
+package initialize
+
+import (
+	"os"
+	"strconv"
+)
+
+// LogConfig is the config of the logs.
+type LogConfig struct {
+	Level      string
+	Format     string
+	File       string
+	MaxSizeMB  int64
+	MaxBackups int
+	Redact     []string
+}
+
+// LoadLogConfigs loads the log configs with the values from .env.
+func LoadLogConfigs() *LogConfig {
+	cfg := &LogConfig{
+		Level:      "info",
+		Format:     "console",
+		File:       "",
+		MaxSizeMB:  100,
+		MaxBackups: 5,
+	}
+
+	if level, exists := os.LookupEnv("LOG_LEVEL"); exists {
+		cfg.Level = level
+	}
+
+	if format, exists := os.LookupEnv("LOG_FORMAT"); exists {
+		cfg.Format = format
+	}
+
+	if file, exists := os.LookupEnv("LOG_FILE"); exists {
+		cfg.File = file
+	}
+
+	if max_size, exists := os.LookupEnv("LOG_MAX_SIZE_MB"); exists {
+		if value, err := strconv.ParseInt(max_size, 10, 64); err == nil && value >= 0 {
+			cfg.MaxSizeMB = value
+		}
+	}
+
+	if max_backups, exists := os.LookupEnv("LOG_MAX_BACKUPS"); exists {
+		if value, err := strconv.Atoi(max_backups); err == nil && value >= 0 {
+			cfg.MaxBackups = value
+		}
+	}
+
+	cfg.Redact = listEnv("LOG_REDACT")
+	return cfg
+}
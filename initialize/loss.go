@@ -0,0 +1,26 @@
+// Package initialize serves to initialize the configs.
+package initialize
+
+import (
+	"os"
+	"strconv"
+)
+
+// LossConfig is the config of the loss limit of the trading day.
+type LossConfig struct {
+	MaxDaily float64
+}
+
+// LoadLossConfigs loads the loss configs with the values from .env.
+func LoadLossConfigs() *LossConfig {
+	cfg := &LossConfig{
+		MaxDaily: 0,
+	}
+
+	if max_daily, exists := os.LookupEnv("MAX_DAILY_LOSS"); exists {
+		if value, err := strconv.ParseFloat(max_daily, 64); err == nil {
+			cfg.MaxDaily = value
+		}
+	}
+	return cfg
+}
@@ -0,0 +1,92 @@
+// Package initialize serves to initialize the configs.
+package initialize
+
+import (
+	"os"
+	"strconv"
+	"strings"
+)
+
+// NotifyConfig is the config of the notifications. A sink is only used when its
+// url or address is set, and the events of a sink are all the events when empty.
+type NotifyConfig struct {
+	WebhookURL    string
+	WebhookEvents []string
+	ChatURL       string
+	ChatFormat    string
+	ChatEvents    []string
+	SMTPAddr      string
+	SMTPFrom      string
+	SMTPTo        []string
+	SMTPUsername  string
+	SMTPPassword  string
+	SMTPEvents    []string
+	PerMinute     float64
+	Burst         int
+}
+
+// LoadNotifyConfigs loads the notification configs with the values from .env.
+func LoadNotifyConfigs() *NotifyConfig {
+	cfg := &NotifyConfig{
+		ChatFormat: "slack",
+		PerMinute:  10,
+		Burst:      5,
+	}
+
+	if url, exists := os.LookupEnv("NOTIFY_WEBHOOK_URL"); exists {
+		cfg.WebhookURL = url
+	}
+	cfg.WebhookEvents = listEnv("NOTIFY_WEBHOOK_EVENTS")
+
+	if url, exists := os.LookupEnv("NOTIFY_CHAT_URL"); exists {
+		cfg.ChatURL = url
+	}
+	if format, exists := os.LookupEnv("NOTIFY_CHAT_FORMAT"); exists && format == "discord" {
+		cfg.ChatFormat = format
+	}
+	cfg.ChatEvents = listEnv("NOTIFY_CHAT_EVENTS")
+
+	if addr, exists := os.LookupEnv("NOTIFY_SMTP_ADDR"); exists {
+		cfg.SMTPAddr = addr
+	}
+	if from, exists := os.LookupEnv("NOTIFY_SMTP_FROM"); exists {
+		cfg.SMTPFrom = from
+	}
+	cfg.SMTPTo = listEnv("NOTIFY_SMTP_TO")
+	if username, exists := os.LookupEnv("NOTIFY_SMTP_USERNAME"); exists {
+		cfg.SMTPUsername = username
+	}
+	if password, exists := os.LookupEnv("NOTIFY_SMTP_PASSWORD"); exists {
+		cfg.SMTPPassword = password
+	}
+	cfg.SMTPEvents = listEnv("NOTIFY_SMTP_EVENTS")
+
+	if per_minute, exists := os.LookupEnv("NOTIFY_RATE_PER_MINUTE"); exists {
+		if value, err := strconv.ParseFloat(per_minute, 64); err == nil {
+			cfg.PerMinute = value
+		}
+	}
+	if burst, exists := os.LookupEnv("NOTIFY_BURST"); exists {
+		if value, err := strconv.Atoi(burst); err == nil {
+			cfg.Burst = value
+		}
+	}
+	return cfg
+}
+
+// listEnv returns the comma separated values of the environment variable, or nil if it
+// is not set.
+func listEnv(key string) []string {
+	value, exists := os.LookupEnv(key)
+	if !exists || value == "" {
+		return nil
+	}
+
+	var values []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			values = append(values, item)
+		}
+	}
+	return values
+}
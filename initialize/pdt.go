@@ -0,0 +1,32 @@
+// Package initialize serves to initialize the configs.
+package initialize
+
+import (
+	"os"
+	"strconv"
+)
+
+// PDTConfig is the config of the local day trade ledger.
+type PDTConfig struct {
+	Ledger bool
+	Policy string
+}
+
+// LoadPDTConfigs loads the day trade configs with the values from .env.
+func LoadPDTConfigs() *PDTConfig {
+	cfg := &PDTConfig{
+		Ledger: false,
+		Policy: "hold",
+	}
+
+	if ledger, exists := os.LookupEnv("PDT_LEDGER"); exists {
+		if value, err := strconv.ParseBool(ledger); err == nil {
+			cfg.Ledger = value
+		}
+	}
+
+	if policy, exists := os.LookupEnv("PDT_POLICY"); exists && policy == "skip" {
+		cfg.Policy = policy
+	}
+	return cfg
+}
@@ -3,12 +3,14 @@ package initialize
 
 import (
 	"os"
+	"strconv"
 )
 
 // RedisConfig is the initial Alpaca api config.
 type RedisConfig struct {
 	Address  string
 	Password string
+	DryRunDB int
 }
 
 // LoadRedisConfigs loads the redis configs with the values from .env.
@@ -16,6 +18,7 @@ func LoadRedisConfigs() *RedisConfig {
 	cfg := &RedisConfig{
 		Address:  "",
 		Password: "",
+		DryRunDB: 1,
 	}
 
 	if redis_address, exists := os.LookupEnv("REDIS_ADDR"); exists {
@@ -25,5 +28,11 @@ func LoadRedisConfigs() *RedisConfig {
 	if redis_password, exists := os.LookupEnv("DB_PASSWORD"); exists {
 		cfg.Password = redis_password
 	}
+
+	if dry_run_db, exists := os.LookupEnv("REDIS_DRY_RUN_DB"); exists {
+		if value, err := strconv.Atoi(dry_run_db); err == nil && value > 0 {
+			cfg.DryRunDB = value
+		}
+	}
 	return cfg
 }
@@ -0,0 +1,30 @@
+// Package initialize serves to initialize the configs.
+package initialize
+
+import (
+	"os"
+	"strings"
+)
+
+// RiskConfig is the config of the risk profiles.
+type RiskConfig struct {
+	ProfilesFile      string
+	OvernightProfiles []string
+}
+
+// LoadRiskConfigs loads the risk configs with the values from .env.
+func LoadRiskConfigs() *RiskConfig {
+	cfg := &RiskConfig{
+		ProfilesFile:      "",
+		OvernightProfiles: nil,
+	}
+
+	if file, exists := os.LookupEnv("RISK_PROFILES_FILE"); exists {
+		cfg.ProfilesFile = file
+	}
+
+	if profiles, exists := os.LookupEnv("OVERNIGHT_PROFILES"); exists && profiles != "" {
+		cfg.OvernightProfiles = strings.Split(profiles, ",")
+	}
+	return cfg
+}
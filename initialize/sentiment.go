@@ -0,0 +1,44 @@
+// Package initialize serves to initialize the configs.
+package initialize
+
+import (
+	"os"
+	"strconv"
+	"time"
+)
+
+// SentimentConfig is the config of the sentiment aggregation. A zero Window
+// disables it and every headline is traded on its own.
+type SentimentConfig struct {
+	Window   time.Duration
+	HalfLife time.Duration
+	Min      int
+}
+
+// LoadSentimentConfigs loads the sentiment configs with the values from .env.
+func LoadSentimentConfigs() *SentimentConfig {
+	cfg := &SentimentConfig{
+		Window:   0,
+		HalfLife: 10 * time.Minute,
+		Min:      1,
+	}
+
+	if window, exists := os.LookupEnv("SENTIMENT_WINDOW"); exists {
+		if value, err := time.ParseDuration(window); err == nil && value >= 0 {
+			cfg.Window = value
+		}
+	}
+
+	if half_life, exists := os.LookupEnv("SENTIMENT_HALF_LIFE"); exists {
+		if value, err := time.ParseDuration(half_life); err == nil && value >= 0 {
+			cfg.HalfLife = value
+		}
+	}
+
+	if min, exists := os.LookupEnv("SENTIMENT_MIN_HEADLINES"); exists {
+		if value, err := strconv.Atoi(min); err == nil && value > 0 {
+			cfg.Min = value
+		}
+	}
+	return cfg
+}
@@ -0,0 +1,48 @@
+// Package initialize serves to initialize the configs.
+package initialize
+
+import (
+	"os"
+	"strconv"
+	"time"
+)
+
+const (
+	// ShutdownDrain lets the pending tasks be processed before stopping.
+	ShutdownDrain = "drain"
+	// ShutdownCancel deletes the pending tasks before stopping.
+	ShutdownCancel = "cancel"
+)
+
+// ShutdownConfig is the config used when the bot stops.
+type ShutdownConfig struct {
+	Policy  string
+	Flatten bool
+	Timeout time.Duration
+}
+
+// LoadShutdownConfigs loads the shutdown configs with the values from .env.
+func LoadShutdownConfigs() *ShutdownConfig {
+	cfg := &ShutdownConfig{
+		Policy:  ShutdownDrain,
+		Flatten: false,
+		Timeout: 30 * time.Second,
+	}
+
+	if policy, exists := os.LookupEnv("SHUTDOWN_POLICY"); exists && policy == ShutdownCancel {
+		cfg.Policy = ShutdownCancel
+	}
+
+	if flatten, exists := os.LookupEnv("SHUTDOWN_FLATTEN"); exists {
+		if value, err := strconv.ParseBool(flatten); err == nil {
+			cfg.Flatten = value
+		}
+	}
+
+	if timeout, exists := os.LookupEnv("SHUTDOWN_TIMEOUT"); exists {
+		if value, err := time.ParseDuration(timeout); err == nil {
+			cfg.Timeout = value
+		}
+	}
+	return cfg
+}
@@ -0,0 +1,47 @@
+// Package initialize serves to initialize the configs.
+package initialize
+
+import (
+	"os"
+	"strconv"
+	"time"
+)
+
+// SignalConfig is the config of the signal resolution of each symbol.
+type SignalConfig struct {
+	Cooldown      time.Duration
+	SameDirection string
+	MaxScaleIns   int
+	Opposite      string
+}
+
+// LoadSignalConfigs loads the signal configs with the values from .env.
+func LoadSignalConfigs() *SignalConfig {
+	cfg := &SignalConfig{
+		Cooldown:      0,
+		SameDirection: "ignore",
+		MaxScaleIns:   0,
+		Opposite:      "flatten",
+	}
+
+	if cooldown, exists := os.LookupEnv("SIGNAL_COOLDOWN"); exists {
+		if value, err := time.ParseDuration(cooldown); err == nil {
+			cfg.Cooldown = value
+		}
+	}
+
+	if same, exists := os.LookupEnv("SIGNAL_SAME_DIRECTION"); exists {
+		cfg.SameDirection = same
+	}
+
+	if scale_ins, exists := os.LookupEnv("SIGNAL_MAX_SCALE_INS"); exists {
+		if value, err := strconv.Atoi(scale_ins); err == nil {
+			cfg.MaxScaleIns = value
+		}
+	}
+
+	if opposite, exists := os.LookupEnv("SIGNAL_OPPOSITE"); exists {
+		cfg.Opposite = opposite
+	}
+	return cfg
+}
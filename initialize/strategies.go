@@ -0,0 +1,23 @@
+// Package initialize serves to initialize the configs.
+package initialize
+
+import (
+	"os"
+)
+
+// StrategyConfig is the config of the shadow strategies.
+type StrategyConfig struct {
+	File string
+}
+
+// LoadStrategyConfigs loads the strategy configs with the values from .env.
+func LoadStrategyConfigs() *StrategyConfig {
+	cfg := &StrategyConfig{
+		File: "",
+	}
+
+	if file, exists := os.LookupEnv("SHADOW_STRATEGIES_FILE"); exists {
+		cfg.File = file
+	}
+	return cfg
+}
@@ -0,0 +1,167 @@
+// Package leader makes sure that only one instance of the bot
+// consumes the news and places orders at a time.
+package leader
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	leaseKey = "leader:lease"
+	fenceKey = "leader:fence"
+)
+
+// acquireScript takes the lease if it is free and increments the fencing token in the
+// same step, so a lease is never held without a token. It returns the new token, or 0
+// if another instance holds the lease.
+var acquireScript = redis.NewScript(`
+if redis.call("set", KEYS[1], ARGV[1], "NX", "PX", ARGV[2]) then
+	return redis.call("incr", KEYS[2])
+end
+return 0`)
+
+// renewScript extends the lease only if it is still owned by the instance.
+var renewScript = redis.NewScript(`
+if redis.call("get", KEYS[1]) == ARGV[1] then
+	return redis.call("pexpire", KEYS[1], ARGV[2])
+end
+return 0`)
+
+// releaseScript deletes the lease only if it is still owned by the instance.
+var releaseScript = redis.NewScript(`
+if redis.call("get", KEYS[1]) == ARGV[1] then
+	return redis.call("del", KEYS[1])
+end
+return 0`)
+
+// ErrLeaseLost is returned when the instance is no longer the leader.
+var ErrLeaseLost = errors.New("leader lease lost")
+
+// A Lease is a redis lock with an expiration, held by the leader instance.
+// Every time the lease is acquired the fencing token is incremented, so orders
+// issued by a previous leader can be recognized and discarded.
+type Lease struct {
+	client *redis.Client
+	id     string
+	ttl    time.Duration
+	token  int64
+}
+
+// NewLease returns a new Lease for this instance with the given time to live.
+func NewLease(client *redis.Client, ttl time.Duration) *Lease {
+	hostname, _ := os.Hostname()
+	return &Lease{
+		client: client,
+		id:     hostname + "-" + strconv.Itoa(os.Getpid()),
+		ttl:    ttl,
+	}
+}
+
+// Token returns the fencing token of the last acquisition.
+func (l *Lease) Token() int64 {
+	return l.token
+}
+
+// TryAcquire returns true if the instance became the leader. It returns false
+// if another instance holds the lease, and an error if redis could not be reached.
+func (l *Lease) TryAcquire(ctx context.Context) (bool, error) {
+	token, err := acquireScript.Run(ctx, l.client, []string{leaseKey, fenceKey}, l.id, l.ttl.Milliseconds()).Int64()
+	if err != nil {
+		return false, fmt.Errorf("unable to acquire the lease: %w", err)
+	}
+	if token == 0 {
+		return false, nil
+	}
+	l.token = token
+	return true, nil
+}
+
+// Acquire blocks until the instance becomes the leader, acting as a hot standby
+// meanwhile. It returns an error if the context is cancelled.
+func (l *Lease) Acquire(ctx context.Context) error {
+	ticker := time.NewTicker(l.ttl / 3)
+	defer ticker.Stop()
+
+	announced := false
+	for {
+		acquired, err := l.TryAcquire(ctx)
+		if err != nil {
+			log.Error().Err(err).Msg("error acquiring the lease")
+		} else if acquired {
+			log.Info().Int64("fence", l.token).Msg("this instance is now the leader")
+			return nil
+		} else if !announced {
+			log.Info().Msg("another instance is the leader, waiting as standby")
+			announced = true
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// KeepAlive renews the lease until the context is cancelled. The returned channel
+// is closed as soon as the lease could not be renewed.
+func (l *Lease) KeepAlive(ctx context.Context) <-chan struct{} {
+	lost := make(chan struct{})
+
+	go func() {
+		defer close(lost)
+		ticker := time.NewTicker(l.ttl / 3)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := l.renew(ctx); err != nil {
+					log.Error().Err(err).Msg("unable to renew the lease")
+					return
+				}
+			}
+		}
+	}()
+	return lost
+}
+
+// Release gives up the lease so a standby can take over right away.
+func (l *Lease) Release(ctx context.Context) error {
+	if err := releaseScript.Run(ctx, l.client, []string{leaseKey}, l.id).Err(); err != nil {
+		return fmt.Errorf("unable to release the lease: %w", err)
+	}
+	return nil
+}
+
+// Valid returns true if the given fencing token belongs to the current leader.
+// It returns false and an error if redis could not be reached.
+func (l *Lease) Valid(ctx context.Context, token int64) (bool, error) {
+	current, err := l.client.Get(ctx, fenceKey).Int64()
+	if err != nil {
+		return false, fmt.Errorf("unable to get the fencing token: %w", err)
+	}
+	return current == token, nil
+}
+
+// renew returns ErrLeaseLost if the lease is no longer owned by the instance.
+func (l *Lease) renew(ctx context.Context) error {
+	renewed, err := renewScript.Run(ctx, l.client, []string{leaseKey}, l.id, l.ttl.Milliseconds()).Int()
+	if err != nil {
+		return fmt.Errorf("unable to renew the lease: %w", err)
+	}
+	if renewed == 0 {
+		return ErrLeaseLost
+	}
+	return nil
+}
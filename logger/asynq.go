@@ -0,0 +1,56 @@
+// Package logger sets up the structured logs of the bot: their level, format and
+// destinations, with the secrets redacted from every line.
+package logger
+
+import (
+	"fmt"
+
+	"github.com/hibiken/asynq"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+)
+
+// Asynq writes the logs of the asynq servers through the global logger, so they get its
+// level, format, redaction and destinations.
+type Asynq struct{}
+
+// Debug logs a message at Debug level.
+func (Asynq) Debug(args ...interface{}) {
+	log.Debug().Str("component", "asynq").Msg(fmt.Sprint(args...))
+}
+
+// Info logs a message at Info level.
+func (Asynq) Info(args ...interface{}) {
+	log.Info().Str("component", "asynq").Msg(fmt.Sprint(args...))
+}
+
+// Warn logs a message at Warning level.
+func (Asynq) Warn(args ...interface{}) {
+	log.Warn().Str("component", "asynq").Msg(fmt.Sprint(args...))
+}
+
+// Error logs a message at Error level.
+func (Asynq) Error(args ...interface{}) {
+	log.Error().Str("component", "asynq").Msg(fmt.Sprint(args...))
+}
+
+// Fatal logs a message at Fatal level and exits.
+func (Asynq) Fatal(args ...interface{}) {
+	log.Fatal().Str("component", "asynq").Msg(fmt.Sprint(args...))
+}
+
+// AsynqLevel returns the asynq log level of the level set up for the logs.
+func AsynqLevel() asynq.LogLevel {
+	switch level := zerolog.GlobalLevel(); {
+	case level <= zerolog.DebugLevel:
+		return asynq.DebugLevel
+	case level == zerolog.InfoLevel:
+		return asynq.InfoLevel
+	case level == zerolog.WarnLevel:
+		return asynq.WarnLevel
+	case level == zerolog.ErrorLevel:
+		return asynq.ErrorLevel
+	default:
+		return asynq.FatalLevel
+	}
+}
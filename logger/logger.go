@@ -0,0 +1,148 @@
+// Package logger sets up the structured logs of the bot: their level, format and
+// destinations, with the secrets redacted from every line.
+package logger
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+)
+
+// The fields shared by the logs of the whole bot.
+const (
+	TaskID  = "task_id"
+	Symbol  = "symbol"
+	NewsID  = "news_id"
+	OrderID = "order_id"
+)
+
+// The formats of the logs.
+const (
+	FormatConsole = "console"
+	FormatJSON    = "json"
+)
+
+// Config has the settings of the logs.
+type Config struct {
+	Level      string
+	Format     string
+	File       string
+	MaxSize    int64
+	MaxBackups int
+	Secrets    []string
+}
+
+var (
+	mu       sync.Mutex
+	format   = FormatConsole
+	redactor = NewRedactor()
+	file     *RotatingFile
+	output   = &swapWriter{out: os.Stdout}
+)
+
+// swapWriter passes the writes to a writer that can be swapped while the loggers made
+// from the global one keep writing to it.
+type swapWriter struct {
+	mu  sync.Mutex
+	out io.Writer
+}
+
+// Write writes p to the current writer.
+func (w *swapWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.out.Write(p)
+}
+
+// swap makes the next writes go to out.
+func (w *swapWriter) swap(out io.Writer) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.out = out
+}
+
+// Setup makes the global logger write to the standard output, and to the rotated file
+// when one is set, at the level and in the format of the config. It is called once, before
+// the logger is used, the destinations are swapped later without replacing it.
+func Setup(cfg Config) error {
+	level, err := zerolog.ParseLevel(strings.ToLower(cfg.Level))
+	if err != nil || level == zerolog.NoLevel {
+		return fmt.Errorf("invalid log level %q", cfg.Level)
+	}
+	if cfg.Format != FormatConsole && cfg.Format != FormatJSON {
+		return fmt.Errorf("invalid log format %q, expected %s or %s", cfg.Format, FormatConsole, FormatJSON)
+	}
+
+	var rotating *RotatingFile
+	if cfg.File != "" {
+		rotating, err = NewRotatingFile(cfg.File, cfg.MaxSize, cfg.MaxBackups)
+		if err != nil {
+			return err
+		}
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	format, file = cfg.Format, rotating
+	redactor.Add(cfg.Secrets...)
+
+	zerolog.SetGlobalLevel(level)
+	zerolog.TimeFieldFormat = time.RFC3339Nano
+	zerolog.DefaultContextLogger = &log.Logger
+	log.Logger = zerolog.New(output).With().Timestamp().Logger()
+	build(os.Stdout, true)
+	return nil
+}
+
+// Redact adds values that are never written to the logs, like the account number.
+func Redact(values ...string) {
+	redactor.Add(values...)
+}
+
+// Redirect makes the logs meant for the standard output go to the writer, without
+// colors, until the returned function is called. The file keeps receiving them.
+func Redirect(out io.Writer) func() {
+	mu.Lock()
+	defer mu.Unlock()
+	build(out, false)
+	return func() {
+		mu.Lock()
+		defer mu.Unlock()
+		build(os.Stdout, true)
+	}
+}
+
+// Close flushes and closes the log file, if there is one.
+func Close() error {
+	mu.Lock()
+	defer mu.Unlock()
+	if file == nil {
+		return nil
+	}
+	// The writes stop going to the file before it is closed.
+	closing := file
+	file = nil
+	build(os.Stdout, true)
+	return closing.Close()
+}
+
+// build makes the global logger write to out and to the file. The file always
+// receives json, so it can be parsed whatever the format of the terminal.
+func build(out io.Writer, color bool) {
+	var terminal io.Writer = redactor.Wrap(out)
+	if format == FormatConsole {
+		terminal = zerolog.ConsoleWriter{Out: terminal, NoColor: !color, TimeFormat: time.TimeOnly}
+	}
+
+	writer := terminal
+	if file != nil {
+		writer = zerolog.MultiLevelWriter(terminal, redactor.Wrap(file))
+	}
+	output.swap(writer)
+}
@@ -0,0 +1,104 @@
+package logger
+
+import (
+	"bytes"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/rs/zerolog/log"
+)
+
+// syncBuffer is a buffer that can be written and read from several goroutines.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func TestRedirect(t *testing.T) {
+	if err := Setup(Config{Level: "info", Format: FormatJSON, Secrets: []string{"hunter22"}}); err != nil {
+		t.Fatalf("setup: %s", err)
+	}
+	// The loggers derived before the redirect follow it.
+	task_log := log.With().Str(TaskID, "1").Logger()
+
+	out := &syncBuffer{}
+	restore := Redirect(out)
+
+	// A worker keeps logging while the output is restored.
+	var wg sync.WaitGroup
+	stop := make(chan struct{})
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+				task_log.Info().Msg("working")
+			}
+		}
+	}()
+
+	task_log.Info().Str("password", "hunter22").Msg("redirected")
+	restore()
+	task_log.Info().Msg("restored")
+	close(stop)
+	wg.Wait()
+
+	logged := out.String()
+	if !strings.Contains(logged, `"task_id":"1"`) || !strings.Contains(logged, "redirected") {
+		t.Errorf("got %q, want the redirected line", logged)
+	}
+	if strings.Contains(logged, "hunter22") {
+		t.Errorf("got %q, want the secret redacted", logged)
+	}
+	if strings.Contains(logged, "restored") {
+		t.Errorf("got %q, want nothing after the restore", logged)
+	}
+}
+
+func TestSetupInvalid(t *testing.T) {
+	if err := Setup(Config{Level: "loud", Format: FormatJSON}); err == nil {
+		t.Error("got no error for an invalid level")
+	}
+	if err := Setup(Config{Level: "info", Format: "xml"}); err == nil {
+		t.Error("got no error for an invalid format")
+	}
+}
+
+func TestAsynq(t *testing.T) {
+	if err := Setup(Config{Level: "warn", Format: FormatJSON}); err != nil {
+		t.Fatalf("setup: %s", err)
+	}
+	if level := AsynqLevel(); level.String() != "warn" {
+		t.Errorf("got asynq level %s, want warn", level.String())
+	}
+
+	out := &syncBuffer{}
+	restore := Redirect(out)
+	Asynq{}.Info("started")
+	Asynq{}.Error("retry ", 1, " failed")
+	restore()
+
+	logged := out.String()
+	if strings.Contains(logged, "started") {
+		t.Errorf("got %q, want the info line filtered", logged)
+	}
+	if !strings.Contains(logged, `"component":"asynq"`) || !strings.Contains(logged, "retry 1 failed") {
+		t.Errorf("got %q, want the error line of asynq", logged)
+	}
+}
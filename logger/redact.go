@@ -0,0 +1,96 @@
+// Package logger sets up the structured logs of the bot: their level, format and
+// destinations, with the secrets redacted from every line.
+package logger
+
+import (
+	"bytes"
+	"io"
+	"regexp"
+	"sort"
+	"sync"
+)
+
+// Redacted replaces the secrets in the logs.
+const Redacted = "[REDACTED]"
+
+// patterns match the secrets that are redacted even when they are not configured.
+var patterns = []struct {
+	re   *regexp.Regexp
+	repl []byte
+}{
+	// Alpaca key ids, of the paper and the live accounts.
+	{regexp.MustCompile(`\b[AP]K[0-9A-Z]{18}\b`), []byte(Redacted)},
+	// OpenAI keys.
+	{regexp.MustCompile(`\bsk-[0-9A-Za-z_-]{20,}`), []byte(Redacted)},
+	// Alpaca account numbers.
+	{regexp.MustCompile(`\bPA[0-9A-Z]{10}\b`), []byte(Redacted)},
+	// Bearer tokens of the authorization headers.
+	{regexp.MustCompile(`(?i)\b(bearer\s+)[^\s"]+`), []byte("${1}" + Redacted)},
+	// Secret fields, in json or as key=value.
+	{
+		regexp.MustCompile(`(?i)("?\b(?:(?:api[_-]?)?key(?:[_-]?id)?|secret(?:[_-]?key)?|password|account[_-]?number|apca-api-[a-z-]+)"?\s*[:=]\s*"?)([^"\s,}&]+)`),
+		[]byte("${1}" + Redacted),
+	},
+}
+
+// Redactor removes the secrets from what is written through it.
+type Redactor struct {
+	mu      sync.RWMutex
+	secrets [][]byte
+}
+
+// NewRedactor returns a redactor of the default patterns.
+func NewRedactor() *Redactor {
+	return &Redactor{}
+}
+
+// Add adds values to redact wherever they appear. The empty ones are ignored.
+func (r *Redactor) Add(values ...string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, value := range values {
+		if len(value) < 4 {
+			continue
+		}
+		r.secrets = append(r.secrets, []byte(value))
+	}
+	// The longest first, so a secret containing another one is fully redacted.
+	sort.Slice(r.secrets, func(i, j int) bool {
+		return len(r.secrets[i]) > len(r.secrets[j])
+	})
+}
+
+// Redact returns the line without the secrets.
+func (r *Redactor) Redact(line []byte) []byte {
+	r.mu.RLock()
+	for _, secret := range r.secrets {
+		line = bytes.ReplaceAll(line, secret, []byte(Redacted))
+	}
+	r.mu.RUnlock()
+
+	for _, pattern := range patterns {
+		line = pattern.re.ReplaceAll(line, pattern.repl)
+	}
+	return line
+}
+
+// Wrap returns a writer that redacts every write before passing it to out. The logger
+// writes a whole event at a time, so a secret is never split across writes.
+func (r *Redactor) Wrap(out io.Writer) io.Writer {
+	return redactWriter{redactor: r, out: out}
+}
+
+// redactWriter is the writer returned by Wrap.
+type redactWriter struct {
+	redactor *Redactor
+	out      io.Writer
+}
+
+// Write writes p without its secrets. It reports the length of p, as the caller
+// does not know about the redaction.
+func (w redactWriter) Write(p []byte) (int, error) {
+	if _, err := w.out.Write(w.redactor.Redact(p)); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
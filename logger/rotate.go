@@ -0,0 +1,125 @@
+// Package logger sets up the structured logs of the bot: their level, format and
+// destinations, with the secrets redacted from every line.
+package logger
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// RotatingFile is a log file that is rotated once it reaches its max size. The
+// rotated files are kept as <path>.1, the newest, up to <path>.<max backups>.
+type RotatingFile struct {
+	mu         sync.Mutex
+	path       string
+	maxSize    int64
+	maxBackups int
+	file       *os.File
+	size       int64
+}
+
+// NewRotatingFile opens the log file at path, appending to it. A max size of zero
+// never rotates it.
+func NewRotatingFile(path string, max_size int64, max_backups int) (*RotatingFile, error) {
+	if dir := filepath.Dir(path); dir != "" {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return nil, fmt.Errorf("unable to create the log directory: %w", err)
+		}
+	}
+
+	r := &RotatingFile{
+		path:       path,
+		maxSize:    max_size,
+		maxBackups: max_backups,
+	}
+	if err := r.open(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Write appends p to the file, rotating it first if p would make it go over the max size.
+func (r *RotatingFile) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.file == nil {
+		return 0, os.ErrClosed
+	}
+	if r.maxSize > 0 && r.size > 0 && r.size+int64(len(p)) > r.maxSize {
+		if err := r.rotate(); err != nil {
+			return 0, err
+		}
+	}
+
+	written, err := r.file.Write(p)
+	r.size += int64(written)
+	return written, err
+}
+
+// Close syncs and closes the file.
+func (r *RotatingFile) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.file == nil {
+		return nil
+	}
+	if err := r.file.Sync(); err != nil {
+		r.file.Close()
+		r.file = nil
+		return fmt.Errorf("unable to sync the log file: %w", err)
+	}
+	err := r.file.Close()
+	r.file = nil
+	return err
+}
+
+// open opens the file at the path, keeping the size it already has.
+func (r *RotatingFile) open() error {
+	file, err := os.OpenFile(r.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return fmt.Errorf("unable to open the log file: %w", err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("unable to stat the log file: %w", err)
+	}
+	r.file, r.size = file, info.Size()
+	return nil
+}
+
+// rotate shifts the backups, dropping the oldest one, moves the current file to the
+// first backup and opens a new one.
+func (r *RotatingFile) rotate() error {
+	if err := r.file.Close(); err != nil {
+		return fmt.Errorf("unable to close the log file: %w", err)
+	}
+	r.file = nil
+
+	if r.maxBackups <= 0 {
+		if err := os.Remove(r.path); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("unable to remove the log file: %w", err)
+		}
+		return r.open()
+	}
+
+	os.Remove(r.backup(r.maxBackups))
+	for i := r.maxBackups - 1; i >= 1; i-- {
+		if err := os.Rename(r.backup(i), r.backup(i+1)); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("unable to rotate the log file: %w", err)
+		}
+	}
+	if err := os.Rename(r.path, r.backup(1)); err != nil {
+		return fmt.Errorf("unable to rotate the log file: %w", err)
+	}
+	return r.open()
+}
+
+// backup returns the path of the nth backup.
+func (r *RotatingFile) backup(n int) string {
+	return fmt.Sprintf("%s.%d", r.path, n)
+}
@@ -1,41 +1,445 @@
 package main
 
 import (
+	"context"
+	"flag"
 	"fmt"
+	"net"
 	"os"
 	"os/signal"
 	"strings"
 	"syscall"
+	"time"
 
 	"github.com/hibiken/asynq"
+	"github.com/jmvdr-iscte/TradingBotCli/approval"
+	"github.com/jmvdr-iscte/TradingBotCli/cash"
 	"github.com/jmvdr-iscte/TradingBotCli/client"
-	"github.com/jmvdr-iscte/TradingBotCli/enums"
+	"github.com/jmvdr-iscte/TradingBotCli/compliance"
+	"github.com/jmvdr-iscte/TradingBotCli/dashboard"
 	"github.com/jmvdr-iscte/TradingBotCli/initialize"
+	"github.com/jmvdr-iscte/TradingBotCli/leader"
+	"github.com/jmvdr-iscte/TradingBotCli/logger"
 	"github.com/jmvdr-iscte/TradingBotCli/models"
+	"github.com/jmvdr-iscte/TradingBotCli/notify"
+	"github.com/jmvdr-iscte/TradingBotCli/pdt"
+	"github.com/jmvdr-iscte/TradingBotCli/position"
+	"github.com/jmvdr-iscte/TradingBotCli/risk"
+	"github.com/jmvdr-iscte/TradingBotCli/sentiment"
 	news "github.com/jmvdr-iscte/TradingBotCli/server"
+	"github.com/jmvdr-iscte/TradingBotCli/session"
+	"github.com/jmvdr-iscte/TradingBotCli/signals"
+	"github.com/jmvdr-iscte/TradingBotCli/strategy"
 	"github.com/jmvdr-iscte/TradingBotCli/worker"
+	"github.com/redis/go-redis/v9"
 	"github.com/rs/zerolog/log"
 )
 
+// restrictionPoll is how often the account restrictions are checked while the trading is halted.
+const restrictionPoll = time.Minute
+
 func main() {
 
+	dry_run := flag.Bool("dry-run", false, "run the whole pipeline without sending any order")
+	flag.Parse()
+
+	if err := setupLogs(); err != nil {
+		log.Fatal().Err(err).Msg("failed to set up the logs")
+	}
+	defer closeLogs()
+
+	redis_config := initialize.LoadRedisConfigs()
+	// A dry-run uses its own database, so it never takes the queued tasks, the lease or
+	// the session of a live instance sharing the redis.
+	redis_db := 0
+	if *dry_run {
+		redis_db = redis_config.DryRunDB
+	}
+
+	redisOpt := asynq.RedisClientOpt{
+		Addr:     redis_config.Address,
+		Password: redis_config.Password,
+		DB:       redis_db,
+	}
+
+	redis_client := redis.NewClient(&redis.Options{
+		Addr:     redis_config.Address,
+		Password: redis_config.Password,
+		DB:       redis_db,
+	})
+	store := session.NewStore(redis_client)
+
+	approval_config := initialize.LoadApprovalConfigs()
+	// `pending`, `approve <id>` and `reject <id>` handle the decisions waiting for approval.
+	if flag.NArg() > 0 {
+		runApprovalCommand(redisOpt, redis_client, approval_config, strings.Join(flag.Args(), " "))
+		return
+	}
+
+	risk_config := initialize.LoadRiskConfigs()
+	profiles, err := risk.Load(risk_config.ProfilesFile)
+	if err != nil {
+		log.Fatal().Err(err).Msg("failed to load the risk profiles")
+	}
+	if err := profiles.EnableOvernight(risk_config.OvernightProfiles); err != nil {
+		log.Fatal().Err(err).Msg("failed to enable the overnight positions")
+	}
+
+	current_session, err := store.Load(context.Background(), session.TradingDate(time.Now()))
+	if err != nil {
+		log.Fatal().Err(err).Msg("failed to load the session")
+	}
+
+	var options models.Options
+	if current_session != nil {
+		log.Info().Str("date", current_session.Date).Str("risk", current_session.Risk).Float64("gain", current_session.Gain).
+			Float64("starting_equity", current_session.StartingValue).Int64("trades", current_session.Trades).
+			Msg("resuming the session")
+		options = sessionOptions(current_session, *dry_run)
+
+		if _, err := profiles.Get(current_session.Risk); err != nil {
+			log.Warn().Str("risk", current_session.Risk).Msg("the risk of the session no longer exists")
+			prompted := promptOptions(profiles)
+			current_session.Risk, options.Risk = prompted.Risk, prompted.Risk
+			if err := store.Save(context.Background(), current_session); err != nil {
+				log.Error().Err(err).Msg("failed to save the session")
+			}
+		}
+	} else {
+		options = promptOptions(profiles)
+	}
+	options.DryRun = *dry_run
+	if options.DryRun {
+		log.Info().Msg("dry-run mode: no order will be sent")
+	}
+
+	shutdown_config := initialize.LoadShutdownConfigs()
+	leader_config := initialize.LoadLeaderConfigs()
+	lease := leader.NewLease(redis_client, leader_config.LeaseTTL)
+
+	task_distributor := worker.NewRedisTaskDistributor(redisOpt)
+	var shadows []strategy.Strategy
+	if strategy_config := initialize.LoadStrategyConfigs(); strategy_config.File != "" {
+		shadows, err = strategy.LoadFile(strategy_config.File, profiles)
+		if err != nil {
+			log.Fatal().Err(err).Msg("failed to load the shadow strategies")
+		}
+		log.Info().Int("strategies", len(shadows)).Msg("evaluating the shadow strategies")
+	}
+	book := strategy.NewBook(redis_client)
+
+	rules, err := compliance.Load(initialize.LoadComplianceConfigs().RulesFile)
+	if err != nil {
+		log.Fatal().Err(err).Msg("failed to load the compliance rules")
+	}
+
+	signal_config := initialize.LoadSignalConfigs()
+	policy := signals.Policy{
+		Cooldown:      signal_config.Cooldown,
+		SameDirection: signal_config.SameDirection,
+		MaxScaleIns:   signal_config.MaxScaleIns,
+		Opposite:      signal_config.Opposite,
+	}
+	if err := policy.Validate(); err != nil {
+		log.Fatal().Err(err).Msg("invalid signal policy")
+	}
+
+	var window *sentiment.Window
+	if sentiment_config := initialize.LoadSentimentConfigs(); sentiment_config.Window > 0 {
+		window = sentiment.NewWindow(redis_client, sentiment_config.Window, sentiment_config.HalfLife, sentiment_config.Min)
+		log.Info().Dur("window", sentiment_config.Window).Msg("aggregating the sentiment")
+	}
+
+	exit_config := initialize.LoadExitConfigs()
+	positions := position.NewManager(redis_client, position.Exits{
+		MaxHold:       exit_config.MaxHold,
+		ProfitTarget:  exit_config.ProfitTarget,
+		ReversalScore: exit_config.ReversalScore,
+	})
+
+	var ledger *pdt.Ledger
+	if pdt_config := initialize.LoadPDTConfigs(); pdt_config.Ledger {
+		ledger = pdt.NewLedger(redis_client, pdt_config.Policy)
+		log.Info().Str("policy", pdt_config.Policy).Msg("tracking the day trades, the policy is used near the limit")
+	}
+
+	var cash_ledger *cash.Ledger
+	if initialize.LoadCashConfigs().Account {
+		cash_ledger = cash.NewLedger(redis_client)
+		log.Info().Msg("trading as a cash account, only with settled funds")
+	}
+
+	var approvals *approval.Store
+	if approval_config.Enabled {
+		if approval_config.Token == "" && !loopback(approval_config.Addr) {
+			log.Fatal().Str("addr", approval_config.Addr).Msg("the approval API listens beyond this machine, set APPROVAL_TOKEN")
+		}
+		approvals = approval.NewStore(redis_client, approval_config.Timeout, approval_config.MinValue)
+		log.Info().Dur("timeout", approval_config.Timeout).Str("addr", approval_config.Addr).Msg("approval mode: the trades wait for approval")
+	}
+
+	notifier, err := loadNotifier()
+	if err != nil {
+		log.Fatal().Err(err).Msg("failed to load the notifications")
+	}
+
+	task_processor := worker.NewRedisTaskProcessor(redisOpt, worker.ProcessorConfig{
+		ShutdownTimeout: shutdown_config.Timeout,
+		Session:         store,
+		Lease:           lease,
+		DryRun:          options.DryRun,
+		Book:            book,
+		Shadows:         shadows,
+		Profiles:        profiles,
+		Compliance:      rules,
+		Signals:         policy,
+		Window:          window,
+		Positions:       positions,
+		Ledger:          ledger,
+		Cash:            cash_ledger,
+		Notifier:        notifier,
+		Approvals:       approvals,
+	})
+
+	// The dashboard redirects the output before the workers start writing to it.
+	var dash *dashboard.Dashboard
+	if dashboard_config := initialize.LoadDashboardConfigs(); dashboard_config.Enabled {
+		dash = dashboard.New(dashboard.Config{
+			Session:   store,
+			Approvals: approvals,
+			RedisOpt:  redisOpt,
+			Refresh:   dashboard_config.Refresh,
+		})
+		if err := dash.Start(); err != nil {
+			log.Error().Err(err).Msg("failed to start the dashboard")
+			dash = nil
+		}
+	}
+
+	runTaskProcessor(task_processor)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if approvals != nil {
+		api := &news.ApprovalAPI{Approvals: approvals, Distributor: task_distributor, Token: approval_config.Token}
+		go func() {
+			if err := api.ListenAndServe(ctx, approval_config.Addr); err != nil {
+				log.Error().Err(err).Msg("failed to serve the approvals")
+			}
+		}()
+	}
+
+	var server *news.NewsServer
+	max_loss := initialize.LoadLossConfigs().MaxDaily
+	started := false
+	for {
+		if err := lease.Acquire(ctx); err != nil {
+			break
+		}
+
+		server = startSession(store, task_distributor, options, lease.Token())
+		if account_number, err := server.AlpacaClient.GetAccountNumber(); err != nil {
+			log.Error().Err(err).Msg("failed to get the account number")
+		} else {
+			logger.Redact(account_number)
+		}
+		server.Positions = positions
+		server.Ledger = ledger
+		server.Notifier = notifier
+		server.MaxLoss = max_loss
+		// The closes made by the monitor are recorded like the ones of the workers.
+		worker.RecordTrades(server.AlpacaClient, store, ledger, notifier)
+		if cash_ledger != nil {
+			server.AlpacaClient.SetCashAccount(cash_ledger)
+		}
+		if server.Profile, err = profiles.Get(server.Options.Risk); err != nil {
+			log.Fatal().Err(err).Msg("failed to get the risk profile of the session")
+		}
+		if server.Profile.Overnight.Enabled {
+			if err := server.ReviewOvernight(); err != nil {
+				log.Error().Err(err).Msg("failed to review the overnight positions")
+			}
+		}
+		if restricted, err := server.CheckRestrictions(); err != nil {
+			log.Error().Err(err).Msg("failed to check the account restrictions")
+		} else if restricted && !waitRestrictions(ctx, server, lease) {
+			break
+		}
+		if started {
+			notifier.Notify(notify.Reconnect, "", "the leadership was acquired again, reconnecting to the news")
+		}
+		started = true
+		notifier.Notify(notify.SessionStart, "", fmt.Sprintf("session started with the %s risk and a gain target of %.2f",
+			server.Options.Risk, server.Options.Gain))
+		dash.SetServer(server)
+		if !runSession(ctx, server, lease) {
+			// The monitor stops the session when the account gets restricted.
+			halted, err := store.Halted(ctx)
+			if err != nil || halted == "" || !waitRestrictions(ctx, server, lease) {
+				break
+			}
+			log.Info().Msg("starting a new session")
+			dash.SetServer(nil)
+			server.Shutdown()
+			server = nil
+			if err := lease.Release(ctx); err != nil {
+				log.Error().Err(err).Msg("failed to release the lease")
+			}
+			continue
+		}
+		log.Warn().Msg("lost the leadership, going back to standby")
+		dash.SetServer(nil)
+		server.Shutdown()
+		server = nil
+	}
+	dash.Stop()
+
+	shutdown(server, task_distributor, task_processor, lease, redisOpt, shutdown_config)
+	if server != nil && len(shadows) > 0 {
+		printShadowReport(book, server)
+	}
+
+	notify_ctx, cancel := context.WithTimeout(context.Background(), shutdown_config.Timeout)
+	defer cancel()
+	if err := notifier.Close(notify_ctx); err != nil {
+		log.Error().Err(err).Msg("failed to send the notifications")
+	}
+	closeLogs()
+}
+
+// sessionOptions returns the run options saved in the session.
+func sessionOptions(current_session *models.Session, dry_run bool) models.Options {
+	return models.Options{
+		Risk:          current_session.Risk,
+		Gain:          current_session.Gain,
+		StartingValue: current_session.StartingValue,
+		DryRun:        dry_run,
+	}
+}
+
+// startSession returns the server of the leader. If another instance already started the
+// session of the day, its baseline is restored, otherwise a new session is saved.
+func startSession(
+	store *session.Store,
+	task_distributor worker.TaskDistributor,
+	options models.Options,
+	fence int64,
+) *news.NewsServer {
+	trading_date := session.TradingDate(time.Now())
+	current_session, err := store.Load(context.Background(), trading_date)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to load the session")
+	} else if current_session != nil {
+		options = sessionOptions(current_session, options.DryRun)
+	}
+
+	server := news.NewServer(task_distributor, &options, store)
+	server.Fence = fence
+
+	if current_session == nil && err == nil {
+		current_session = &models.Session{
+			Date:          trading_date,
+			StartingValue: server.Options.StartingValue,
+			Risk:          server.Options.Risk,
+			Gain:          server.Options.Gain,
+		}
+		if err := store.Save(context.Background(), current_session); err != nil {
+			log.Error().Err(err).Msg("failed to save the session")
+		}
+	}
+	return server
+}
+
+// runSession consumes the news while the instance is the leader. It returns true if
+// the leadership was lost, and false if the session ended or the bot is stopping.
+func runSession(ctx context.Context, server *news.NewsServer, lease *leader.Lease) bool {
+	sessionCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	lost := lease.KeepAlive(sessionCtx)
+	sessionCh := make(chan error, 1)
+	go func() {
+		sessionCh <- client.ConnectToWebSocket(server)
+	}()
+
+	select {
+	case <-ctx.Done():
+		log.Info().Msg("received shutdown signal")
+		return false
+	case err := <-sessionCh:
+		if err != nil {
+			log.Error().Err(err).Msg("the news session failed")
+		}
+		return false
+	case <-lost:
+		return ctx.Err() == nil
+	}
+}
+
+// loopback returns true if the address only listens on this machine.
+func loopback(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return false
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// waitRestrictions returns true once the account restrictions are lifted. They are checked
+// every restrictionPoll while the lease is kept. It returns false if the bot is shut down
+// or the lease is lost first.
+func waitRestrictions(ctx context.Context, server *news.NewsServer, lease *leader.Lease) bool {
+	waitCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	lost := lease.KeepAlive(waitCtx)
+	ticker := time.NewTicker(restrictionPoll)
+	defer ticker.Stop()
+
+	log.Warn().Dur("poll", restrictionPoll).Msg("the trading is halted, waiting for the account restrictions to be lifted")
+	for {
+		select {
+		case <-ctx.Done():
+			return false
+		case <-lost:
+			return false
+		case <-ticker.C:
+		}
+		restricted, err := server.CheckRestrictions()
+		if err != nil {
+			log.Error().Err(err).Msg("failed to check the account restrictions")
+			continue
+		}
+		if !restricted {
+			return true
+		}
+	}
+}
+
+// promptOptions asks the user for the risk profile and the expected gain of the day.
+func promptOptions(profiles risk.Profiles) models.Options {
 	var risk_value string
-	var risk enums.Risk
+	var profile risk.Profile
 	var stop_gain float64
 	var err error
 
+	names := strings.Join(profiles.Names(), ", ")
 	for {
-		fmt.Println("Please select your preferred risk: Safe, Low, Medium, High, Power")
+		fmt.Println("Please select your preferred risk:", names)
 		fmt.Scanln(&risk_value)
-		risk_value = strings.ToLower(strings.TrimSpace(risk_value))
-		risk, err = coerceToRisk(risk_value)
+		profile, err = profiles.Get(risk_value)
 		if err != nil {
-			fmt.Println("Invalid input. Please enter Safe Low, Medium, High or Power.")
+			fmt.Println("Invalid input. Please enter one of:", names)
 		} else {
 			break
 		}
 	}
-	fmt.Println("You selected:", risk.String())
+	fmt.Println("You selected:", profile.Name)
 
 	for {
 		fmt.Println("Please select your expected gain today")
@@ -48,61 +452,162 @@ func main() {
 	}
 	fmt.Println("You selected:", stop_gain)
 
-	options := models.Options{
-		Risk: risk,
+	return models.Options{
+		Risk: profile.Name,
 		Gain: stop_gain,
 	}
+}
 
-	redis_config := initialize.LoadRedisConfigs()
+func runTaskProcessor(task_processor worker.TaskProcessor) {
+	log.Info().Msg("start task processor")
+	err := task_processor.Start()
+	if err != nil {
+		log.Fatal().Err(err).Msg("failed to start task processor")
+	}
+}
 
-	redisOpt := asynq.RedisClientOpt{
-		Addr:     redis_config.Address,
-		Password: redis_config.Password,
+// shutdown stops the news intake, drains or cancels the pending orders according to the
+// shutdown policy, optionally flattens the positions and prints the session summary.
+// The server is nil if the instance was a standby.
+func shutdown(
+	server *news.NewsServer,
+	task_distributor worker.TaskDistributor,
+	task_processor worker.TaskProcessor,
+	lease *leader.Lease,
+	redisOpt asynq.RedisClientOpt,
+	cfg *initialize.ShutdownConfig,
+) {
+	if server != nil {
+		server.Shutdown()
+
+		if cfg.Policy == initialize.ShutdownCancel {
+			deleted, err := worker.CancelPendingTasks(redisOpt)
+			if err != nil {
+				log.Error().Err(err).Msg("failed to cancel pending tasks")
+			}
+			log.Info().Msgf("cancelled %d pending tasks", deleted)
+		} else if err := worker.DrainQueues(redisOpt, cfg.Timeout); err != nil {
+			log.Error().Err(err).Msg("failed to drain the queues")
+		}
 	}
 
-	task_distributor := worker.NewRedisTaskDistributor(redisOpt)
-	go runTaskProcessor(redisOpt) // tem de ser numa go routine pois tal como um servidor http, ele bloqueia se não tiver pedidos
+	task_processor.Shutdown()
+	if err := task_distributor.Close(); err != nil {
+		log.Error().Err(err).Msg("failed to close the task distributor")
+	}
+
+	if server == nil {
+		return
+	}
+
+	if cfg.Flatten {
+		if err := server.AlpacaClient.ClosePositions(); err != nil {
+			log.Error().Err(err).Msg("failed to flatten positions")
+		}
+	}
+
+	if err := lease.Release(context.Background()); err != nil {
+		log.Error().Err(err).Msg("failed to release the leader lease")
+	}
 
-	server := news.NewServer(task_distributor, &options)
-	err = client.ConnectToWebSocket(server)
+	summary, err := server.Summary()
 	if err != nil {
-		fmt.Println(err)
+		log.Error().Err(err).Msg("failed to build the session summary")
+		server.Notifier.Notify(notify.SessionStop, "", "session stopped")
+		return
 	}
-	sigCh := make(chan os.Signal, 1)
-	signal.Notify(sigCh, os.Interrupt, syscall.SIGTERM)
+	// The session journal is flushed with the final P&L, after the flatten.
+	if err := server.RecordRealizedPnL(summary.Equity); err != nil {
+		log.Error().Err(err).Msg("failed to record the realized P&L")
+	}
+	log.Info().Time("started_at", summary.StartedAt).Int("trades", summary.Trades).Int("buys", summary.Buys).
+		Int("sells", summary.Sells).Float64("starting_equity", summary.StartingValue).Float64("equity", summary.Equity).
+		Float64("pnl", summary.PnL).Msg("session summary")
+	server.Notifier.Notify(notify.SessionStop, "", summary.String())
+}
 
-	go func() {
-		<-sigCh
-		server.Shutdown()
+// runApprovalCommand runs a command of the decisions waiting for approval and prints the reply.
+func runApprovalCommand(redisOpt asynq.RedisClientOpt, redis_client *redis.Client, cfg *initialize.ApprovalConfig, command string) {
+	task_distributor := worker.NewRedisTaskDistributor(redisOpt)
+	defer task_distributor.Close()
 
-		os.Exit(0)
-	}()
+	api := &news.ApprovalAPI{
+		Approvals:   approval.NewStore(redis_client, cfg.Timeout, cfg.MinValue),
+		Distributor: task_distributor,
+	}
+	fmt.Println(api.Command(context.Background(), command))
+}
+
+// loadNotifier returns the notifier of the sinks set in the config, or nil if there are none.
+func loadNotifier() (*notify.Notifier, error) {
+	cfg := initialize.LoadNotifyConfigs()
+
+	var routes []notify.Route
+	if cfg.WebhookURL != "" {
+		routes = append(routes, notify.Route{Name: "webhook", Sink: notify.NewWebhook(cfg.WebhookURL), Events: cfg.WebhookEvents})
+	}
+	if cfg.ChatURL != "" {
+		routes = append(routes, notify.Route{Name: "chat", Sink: notify.NewChat(cfg.ChatURL, cfg.ChatFormat), Events: cfg.ChatEvents})
+	}
+	if cfg.SMTPAddr != "" {
+		sink := notify.NewEmail(cfg.SMTPAddr, cfg.SMTPFrom, cfg.SMTPTo, cfg.SMTPUsername, cfg.SMTPPassword)
+		routes = append(routes, notify.Route{Name: "email", Sink: sink, Events: cfg.SMTPEvents})
+	}
+	if len(routes) == 0 {
+		return nil, nil
+	}
 
-	select {}
+	for i := range routes {
+		routes[i].PerMinute = cfg.PerMinute
+		routes[i].Burst = cfg.Burst
+	}
+	return notify.New(routes)
 }
 
-func runTaskProcessor(redisOpt asynq.RedisClientOpt) {
-	task_processor := worker.NewRedisTaskProcessor(redisOpt)
-	log.Info().Msg("start task processor")
-	err := task_processor.Start()
+// printShadowReport prints the P&L of the live strategy next to the shadow ones,
+// so they can be compared before promoting one.
+func printShadowReport(book *strategy.Book, server *news.NewsServer) {
+	results, err := book.Report(context.Background(), session.TradingDate(time.Now()), server.AlpacaClient.GetPrice)
 	if err != nil {
-		log.Fatal().Err(err).Msg("failed to start task processor")
+		log.Error().Err(err).Msg("failed to build the shadow report")
+		return
+	}
+	log.Info().Msgf("shadow strategies report:\n%s", strings.TrimRight(strategy.FormatReport(results), "\n"))
+}
+
+// setupLogs sets up the logs from the config. The credentials of every config are
+// redacted from them, along with the extra values of LOG_REDACT.
+func setupLogs() error {
+	cfg := initialize.LoadLogConfigs()
+	alpaca_config := initialize.LoadAlpaca()
+	notify_config := initialize.LoadNotifyConfigs()
+
+	secrets := []string{
+		alpaca_config.ID,
+		alpaca_config.Secret,
+		initialize.LoadOpenAIClient().OpenAIKey,
+		initialize.LoadRedisConfigs().Password,
+		initialize.LoadApprovalConfigs().Token,
+		notify_config.WebhookURL,
+		notify_config.ChatURL,
+		notify_config.SMTPPassword,
 	}
+	return logger.Setup(logger.Config{
+		Level:      cfg.Level,
+		Format:     cfg.Format,
+		File:       cfg.File,
+		MaxSize:    cfg.MaxSizeMB << 20,
+		MaxBackups: cfg.MaxBackups,
+		Secrets:    append(secrets, cfg.Redact...),
+	})
 }
 
-func coerceToRisk(risk_str string) (enums.Risk, error) {
-	switch strings.ToLower(risk_str) {
-	case "safe":
-		return enums.Safe, nil
-	case "low":
-		return enums.Low, nil
-	case "medium":
-		return enums.Medium, nil
-	case "high":
-		return enums.High, nil
-	case "power":
-		return enums.Power, nil
-	default:
-		return 0, fmt.Errorf("invalid value for Risk: %s", risk_str)
+// closeLogs flushes the output and closes the log file. It is the last step of the
+// shutdown, and is safe to call more than once.
+func closeLogs() {
+	if err := logger.Close(); err != nil {
+		log.Error().Err(err).Msg("failed to close the log file")
 	}
+	os.Stdout.Sync()
+	os.Stderr.Sync()
 }
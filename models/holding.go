@@ -0,0 +1,14 @@
+// Package models serve as structs used in the application.
+package models
+
+// Holding is an open position. Qty and MarketValue are negative for shorts, and
+// UnrealizedPLPercent is a fraction of the cost basis.
+type Holding struct {
+	Symbol              string  `json:"symbol"`
+	Qty                 float64 `json:"qty"`
+	AvgEntryPrice       float64 `json:"avg_entry_price"`
+	CurrentPrice        float64 `json:"current_price"`
+	MarketValue         float64 `json:"market_value"`
+	UnrealizedPL        float64 `json:"unrealized_pl"`
+	UnrealizedPLPercent float64 `json:"unrealized_plpc"`
+}
@@ -0,0 +1,13 @@
+// Package models serve as structs used in the application.
+package models
+
+import "time"
+
+// ScoredNews is a headline with the sentiment score it was given.
+type ScoredNews struct {
+	ID       int64     `json:"id"`
+	Headline string    `json:"headline"`
+	Symbols  []string  `json:"symbols"`
+	Score    int       `json:"score"`
+	Time     time.Time `json:"time"`
+}
@@ -0,0 +1,13 @@
+// Package models serve as structs used in the application.
+package models
+
+// Session is the state of a trading day. It is persisted so a restart
+// in the middle of the day keeps the same baseline.
+type Session struct {
+	Date          string  `redis:"date"`
+	StartingValue float64 `redis:"starting_value"`
+	RealizedPnL   float64 `redis:"realized_pnl"`
+	Trades        int64   `redis:"trades"`
+	Risk          string  `redis:"risk"`
+	Gain          float64 `redis:"gain"`
+}
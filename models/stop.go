@@ -0,0 +1,19 @@
+// Package models serve as structs used in the application.
+package models
+
+// SoftStop is a stop loss watched by the bot instead of the broker, used
+// outside the regular session where the stop orders are not triggered.
+// Side is the side of the order that closes the position.
+type SoftStop struct {
+	Symbol string  `json:"symbol"`
+	Side   string  `json:"side"`
+	Price  float64 `json:"price"`
+}
+
+// Triggered returns true if the stop is hit at the given price.
+func (s SoftStop) Triggered(price float64) bool {
+	if s.Side == "sell" {
+		return price <= s.Price
+	}
+	return price >= s.Price
+}
@@ -0,0 +1,26 @@
+// Package models serve as structs used in the application.
+package models
+
+import (
+	"fmt"
+	"time"
+)
+
+// Summary is the report of a trading session, printed when the bot stops.
+type Summary struct {
+	StartedAt     time.Time `json:"started_at"`
+	StartingValue float64   `json:"starting_value"`
+	Equity        float64   `json:"equity"`
+	PnL           float64   `json:"pnl"`
+	Trades        int       `json:"trades"`
+	Buys          int       `json:"buys"`
+	Sells         int       `json:"sells"`
+}
+
+// String returns the summary in a human readable format.
+func (s Summary) String() string {
+	return fmt.Sprintf(
+		"session started at %s\n trades: %d (%d buys, %d sells)\n starting equity: %.2f\n final equity: %.2f\n P&L: %.2f",
+		s.StartedAt.Format(time.Kitchen), s.Trades, s.Buys, s.Sells, s.StartingValue, s.Equity, s.PnL,
+	)
+}
@@ -0,0 +1,60 @@
+// Package notify pushes the events of the bot to webhooks and email. Every sink only
+// receives the events it is interested in, and is rate limited per event so a burst of
+// fills does not flood it.
+package notify
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/smtp"
+	"strings"
+	"time"
+)
+
+// Email sends the events by email through an SMTP server.
+type Email struct {
+	Addr string
+	From string
+	To   []string
+	auth smtp.Auth
+}
+
+// NewEmail returns a new Email that sends through the SMTP server at addr, host:port.
+// The server is only authenticated against when there is a username.
+func NewEmail(addr string, from string, to []string, username string, password string) *Email {
+	email := &Email{
+		Addr: addr,
+		From: from,
+		To:   to,
+	}
+	if username != "" {
+		host, _, err := net.SplitHostPort(addr)
+		if err != nil {
+			host = addr
+		}
+		email.auth = smtp.PlainAuth("", username, password, host)
+	}
+	return email
+}
+
+// Send sends the event by email. The SMTP client can't be cancelled, so the context is
+// only checked before sending.
+func (e *Email) Send(ctx context.Context, event Event) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	var msg strings.Builder
+	fmt.Fprintf(&msg, "From: %s\r\n", e.From)
+	fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(e.To, ", "))
+	fmt.Fprintf(&msg, "Subject: TradingBotCli %s\r\n", event.Kind)
+	fmt.Fprintf(&msg, "Date: %s\r\n", event.Time.Format(time.RFC1123Z))
+	msg.WriteString("Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	msg.WriteString(event.Text() + "\r\n")
+
+	if err := smtp.SendMail(e.Addr, e.auth, e.From, e.To, []byte(msg.String())); err != nil {
+		return fmt.Errorf("unable to send the notification email: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,134 @@
+package notify
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"net/textproto"
+	"strings"
+	"testing"
+	"time"
+)
+
+// message is what the SMTP stand-in received.
+type message struct {
+	from string
+	to   []string
+	data string
+}
+
+// smtpServer runs a minimal SMTP server on the loopback that accepts one message, or
+// rejects it after the data when reject is true. It returns its address.
+func smtpServer(t *testing.T, reject bool, messages chan<- message) string {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %s", err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		text := textproto.NewConn(conn)
+		var received message
+
+		text.PrintfLine("220 localhost ready")
+		for {
+			line, err := text.ReadLine()
+			if err != nil {
+				return
+			}
+			command := strings.ToUpper(line)
+			switch {
+			case strings.HasPrefix(command, "EHLO"), strings.HasPrefix(command, "HELO"):
+				text.PrintfLine("250 localhost")
+			case strings.HasPrefix(command, "MAIL FROM:"):
+				received.from = strings.Trim(line[len("MAIL FROM:"):], "<> ")
+				text.PrintfLine("250 OK")
+			case strings.HasPrefix(command, "RCPT TO:"):
+				received.to = append(received.to, strings.Trim(line[len("RCPT TO:"):], "<> "))
+				text.PrintfLine("250 OK")
+			case command == "DATA":
+				text.PrintfLine("354 end with <CR><LF>.<CR><LF>")
+				data, err := text.ReadDotBytes()
+				if err != nil {
+					return
+				}
+				if reject {
+					text.PrintfLine("554 message rejected")
+					continue
+				}
+				received.data = string(data)
+				messages <- received
+				text.PrintfLine("250 OK")
+			case command == "QUIT":
+				text.PrintfLine("221 bye")
+				return
+			default:
+				text.PrintfLine("502 command not implemented")
+			}
+		}
+	}()
+	return listener.Addr().String()
+}
+
+func TestEmailSend(t *testing.T) {
+	messages := make(chan message, 1)
+	addr := smtpServer(t, false, messages)
+
+	email := NewEmail(addr, "bot@example.com", []string{"me@example.com", "ops@example.com"}, "", "")
+	event := Event{Kind: MaxLoss, Time: time.Date(2024, 3, 4, 15, 0, 0, 0, time.UTC), Message: "lost 500.00, closing the positions"}
+	if err := email.Send(context.Background(), event); err != nil {
+		t.Fatalf("send: %s", err)
+	}
+
+	received := <-messages
+	if received.from != "bot@example.com" {
+		t.Errorf("got sender %q, want bot@example.com", received.from)
+	}
+	if strings.Join(received.to, ",") != "me@example.com,ops@example.com" {
+		t.Errorf("got recipients %v, want me@example.com and ops@example.com", received.to)
+	}
+
+	header, err := textproto.NewReader(bufio.NewReader(strings.NewReader(received.data))).ReadMIMEHeader()
+	if err != nil {
+		t.Fatalf("parse the message: %s", err)
+	}
+	want := map[string]string{
+		"From":    "bot@example.com",
+		"To":      "me@example.com, ops@example.com",
+		"Subject": "TradingBotCli max_loss",
+		"Date":    "Mon, 04 Mar 2024 15:00:00 +0000",
+	}
+	for key, value := range want {
+		if got := header.Get(key); got != value {
+			t.Errorf("got %s %q, want %q", key, got, value)
+		}
+	}
+	if !strings.Contains(received.data, "\n"+event.Text()+"\n") {
+		t.Errorf("got %q, want the body %q", received.data, event.Text())
+	}
+}
+
+func TestEmailRejected(t *testing.T) {
+	addr := smtpServer(t, true, make(chan message, 1))
+
+	email := NewEmail(addr, "bot@example.com", []string{"me@example.com"}, "", "")
+	if err := email.Send(context.Background(), Event{Kind: Error, Message: "failed"}); err == nil {
+		t.Error("got no error for a rejected message")
+	}
+}
+
+func TestEmailCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	email := NewEmail("127.0.0.1:1", "bot@example.com", []string{"me@example.com"}, "", "")
+	if err := email.Send(ctx, Event{Kind: Error, Message: "failed"}); err == nil {
+		t.Error("got no error for a cancelled context")
+	}
+}
@@ -0,0 +1,187 @@
+// Package notify pushes the events of the bot to webhooks and email. Every sink only
+// receives the events it is interested in, and is rate limited per event so a burst of
+// fills does not flood it.
+package notify
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"golang.org/x/time/rate"
+)
+
+// The events the bot pushes.
+const (
+	SessionStart  = "session_start"
+	SessionStop   = "session_stop"
+	Fill          = "fill"
+	StopTriggered = "stop_triggered"
+	GainTarget    = "gain_target"
+	MaxLoss       = "max_loss"
+	Error         = "error"
+	Reconnect     = "reconnect"
+	// ApprovalPending is a trade waiting for approval.
+	ApprovalPending = "approval_pending"
+)
+
+const (
+	queueSize   = 256
+	sendTimeout = 10 * time.Second
+)
+
+// Events are every event the bot pushes.
+var Events = []string{SessionStart, SessionStop, Fill, StopTriggered, GainTarget, MaxLoss, Error, Reconnect, ApprovalPending}
+
+// Event is something that happened in the bot.
+type Event struct {
+	Kind    string    `json:"event"`
+	Time    time.Time `json:"time"`
+	Symbol  string    `json:"symbol,omitempty"`
+	Message string    `json:"message"`
+}
+
+// Text returns the event as a single line of text.
+func (e Event) Text() string {
+	if e.Symbol != "" {
+		return fmt.Sprintf("[%s] %s: %s", e.Kind, e.Symbol, e.Message)
+	}
+	return fmt.Sprintf("[%s] %s", e.Kind, e.Message)
+}
+
+// Sink sends the events somewhere.
+type Sink interface {
+	Send(ctx context.Context, event Event) error
+}
+
+// Route sends the events of the given kinds to a sink, every event if there are none.
+// Every kind of event is sent at most PerMinute times a minute, with bursts of Burst.
+type Route struct {
+	Name      string
+	Sink      Sink
+	Events    []string
+	PerMinute float64
+	Burst     int
+
+	events   map[string]bool
+	limiters map[string]*rate.Limiter
+}
+
+// accepts returns true if the event should be sent to the sink of the route now.
+func (r *Route) accepts(event Event) bool {
+	if len(r.events) > 0 && !r.events[event.Kind] {
+		return false
+	}
+	if r.PerMinute <= 0 {
+		return true
+	}
+
+	limiter, exists := r.limiters[event.Kind]
+	if !exists {
+		limiter = rate.NewLimiter(rate.Limit(r.PerMinute/60), max(r.Burst, 1))
+		r.limiters[event.Kind] = limiter
+	}
+	return limiter.Allow()
+}
+
+// Notifier sends the events to the routes in the background, so the trading is never
+// delayed by a slow sink. A nil Notifier drops every event.
+type Notifier struct {
+	routes []*Route
+	events chan Event
+	done   chan struct{}
+	mu     sync.RWMutex
+	closed bool
+}
+
+// New returns a new Notifier that sends the events to the given routes. It returns an
+// error if a route has an unknown event.
+func New(routes []Route) (*Notifier, error) {
+	n := &Notifier{
+		events: make(chan Event, queueSize),
+		done:   make(chan struct{}),
+	}
+	for _, route := range routes {
+		route := route
+		route.events = make(map[string]bool, len(route.Events))
+		route.limiters = make(map[string]*rate.Limiter)
+		for _, kind := range route.Events {
+			if !known(kind) {
+				return nil, fmt.Errorf("unknown event %q of the %s notifications", kind, route.Name)
+			}
+			route.events[kind] = true
+		}
+		n.routes = append(n.routes, &route)
+	}
+
+	go n.run()
+	return n, nil
+}
+
+// Notify queues an event of the given kind. The event is dropped if the queue is full.
+func (n *Notifier) Notify(kind string, symbol string, message string) {
+	if n == nil {
+		return
+	}
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+	if n.closed {
+		return
+	}
+
+	select {
+	case n.events <- Event{Kind: kind, Time: time.Now(), Symbol: symbol, Message: message}:
+	default:
+		log.Warn().Str("event", kind).Msg("notification queue full, dropping the event")
+	}
+}
+
+// Close stops accepting events and waits until the queued ones are sent, or the
+// context is done.
+func (n *Notifier) Close(ctx context.Context) error {
+	if n == nil {
+		return nil
+	}
+	n.mu.Lock()
+	if !n.closed {
+		n.closed = true
+		close(n.events)
+	}
+	n.mu.Unlock()
+
+	select {
+	case <-n.done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("unable to send the queued notifications: %w", ctx.Err())
+	}
+}
+
+// run sends the queued events until the notifier is closed.
+func (n *Notifier) run() {
+	defer close(n.done)
+	for event := range n.events {
+		for _, route := range n.routes {
+			if !route.accepts(event) {
+				continue
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), sendTimeout)
+			if err := route.Sink.Send(ctx, event); err != nil {
+				log.Error().Err(err).Str("sink", route.Name).Str("event", event.Kind).Msg("failed to send the notification")
+			}
+			cancel()
+		}
+	}
+}
+
+// known returns true if the kind is an event the bot pushes.
+func known(kind string) bool {
+	for _, event := range Events {
+		if event == kind {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,105 @@
+package notify
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+// recordSink keeps the events sent to it.
+type recordSink struct {
+	mu     sync.Mutex
+	events []Event
+}
+
+func (s *recordSink) Send(ctx context.Context, event Event) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.events = append(s.events, event)
+	return nil
+}
+
+func (s *recordSink) kinds() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	kinds := make([]string, 0, len(s.events))
+	for _, event := range s.events {
+		kinds = append(kinds, event.Kind)
+	}
+	return kinds
+}
+
+func TestNotifierRoutes(t *testing.T) {
+	fills, everything := &recordSink{}, &recordSink{}
+	notifier, err := New([]Route{
+		{Name: "fills", Sink: fills, Events: []string{Fill}},
+		{Name: "everything", Sink: everything},
+	})
+	if err != nil {
+		t.Fatalf("new: %s", err)
+	}
+
+	notifier.Notify(Fill, "AAPL", "buy 10")
+	notifier.Notify(Error, "", "failed")
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := notifier.Close(ctx); err != nil {
+		t.Fatalf("close: %s", err)
+	}
+
+	if got := fills.kinds(); len(got) != 1 || got[0] != Fill {
+		t.Errorf("fills route got %v, want [%s]", got, Fill)
+	}
+	if got := everything.kinds(); len(got) != 2 {
+		t.Errorf("route without events got %v, want every event", got)
+	}
+
+	// The events after the close are dropped.
+	notifier.Notify(Fill, "AAPL", "buy 10")
+}
+
+func TestNotifierUnknownEvent(t *testing.T) {
+	if _, err := New([]Route{{Name: "typo", Sink: &recordSink{}, Events: []string{"fills"}}}); err == nil {
+		t.Error("got no error for an unknown event")
+	}
+}
+
+func TestRouteRateLimit(t *testing.T) {
+	sink := &recordSink{}
+	notifier, err := New([]Route{{Name: "limited", Sink: sink, PerMinute: 1, Burst: 2}})
+	if err != nil {
+		t.Fatalf("new: %s", err)
+	}
+
+	for i := 0; i < 5; i++ {
+		notifier.Notify(Fill, "AAPL", "buy 1")
+	}
+	notifier.Notify(Error, "", "failed")
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := notifier.Close(ctx); err != nil {
+		t.Fatalf("close: %s", err)
+	}
+
+	// Every kind of event has its own burst.
+	want := []string{Fill, Fill, Error}
+	got := sink.kinds()
+	if len(got) != len(want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("got %v, want %v", got, want)
+			break
+		}
+	}
+}
+
+func TestNilNotifier(t *testing.T) {
+	var notifier *Notifier
+	notifier.Notify(Fill, "AAPL", "buy 10")
+	if err := notifier.Close(context.Background()); err != nil {
+		t.Errorf("close: %s", err)
+	}
+}
@@ -0,0 +1,88 @@
+// Package notify pushes the events of the bot to webhooks and email. Every sink only
+// receives the events it is interested in, and is rate limited per event so a burst of
+// fills does not flood it.
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+const (
+	// ChatSlack formats the messages for a Slack incoming webhook.
+	ChatSlack = "slack"
+	// ChatDiscord formats the messages for a Discord webhook.
+	ChatDiscord = "discord"
+)
+
+// Webhook posts the events as json to a url.
+type Webhook struct {
+	URL    string
+	Client *http.Client
+}
+
+// NewWebhook returns a new Webhook that posts to the given url.
+func NewWebhook(url string) *Webhook {
+	return &Webhook{
+		URL:    url,
+		Client: &http.Client{},
+	}
+}
+
+// Send posts the event.
+func (w *Webhook) Send(ctx context.Context, event Event) error {
+	return post(ctx, w.Client, w.URL, event)
+}
+
+// Chat posts the events as a message to a Slack or a Discord compatible incoming webhook.
+type Chat struct {
+	URL    string
+	Format string
+	Client *http.Client
+}
+
+// NewChat returns a new Chat that posts to the given url with the given format.
+func NewChat(url string, format string) *Chat {
+	return &Chat{
+		URL:    url,
+		Format: format,
+		Client: &http.Client{},
+	}
+}
+
+// Send posts the event as a message.
+func (c *Chat) Send(ctx context.Context, event Event) error {
+	if c.Format == ChatDiscord {
+		return post(ctx, c.Client, c.URL, map[string]string{"content": event.Text()})
+	}
+	return post(ctx, c.Client, c.URL, map[string]string{"text": event.Text()})
+}
+
+// post posts the payload as json to the url. It returns an error if the response is not
+// a success.
+func post(ctx context.Context, client *http.Client, url string, payload interface{}) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("unable to marshal the notification: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("unable to build the notification request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("unable to post the notification: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("notification rejected with status %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,87 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// recordServer returns a server that decodes the json posted to it into the payloads,
+// and answers with the given status.
+func recordServer(t *testing.T, status int, payloads chan<- map[string]interface{}) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			t.Errorf("got method %s, want POST", r.Method)
+		}
+		if content := r.Header.Get("Content-Type"); content != "application/json" {
+			t.Errorf("got content type %q, want application/json", content)
+		}
+		var payload map[string]interface{}
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+			t.Errorf("unable to decode the payload: %s", err)
+		}
+		payloads <- payload
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestWebhookSend(t *testing.T) {
+	payloads := make(chan map[string]interface{}, 1)
+	server := recordServer(t, http.StatusOK, payloads)
+
+	event := Event{Kind: Fill, Time: time.Date(2024, 3, 4, 10, 0, 0, 0, time.UTC), Symbol: "AAPL", Message: "buy 10"}
+	if err := NewWebhook(server.URL).Send(context.Background(), event); err != nil {
+		t.Fatalf("send: %s", err)
+	}
+
+	payload := <-payloads
+	want := map[string]interface{}{
+		"event":   Fill,
+		"time":    "2024-03-04T10:00:00Z",
+		"symbol":  "AAPL",
+		"message": "buy 10",
+	}
+	for key, value := range want {
+		if payload[key] != value {
+			t.Errorf("got %s %v, want %v", key, payload[key], value)
+		}
+	}
+}
+
+func TestWebhookRejected(t *testing.T) {
+	payloads := make(chan map[string]interface{}, 1)
+	server := recordServer(t, http.StatusInternalServerError, payloads)
+
+	if err := NewWebhook(server.URL).Send(context.Background(), Event{Kind: Error, Message: "failed"}); err == nil {
+		t.Error("got no error for a rejected notification")
+	}
+}
+
+func TestChatSend(t *testing.T) {
+	tests := []struct {
+		format string
+		key    string
+	}{
+		{ChatSlack, "text"},
+		{ChatDiscord, "content"},
+	}
+	event := Event{Kind: StopTriggered, Symbol: "TSLA", Message: "sell 5 filled at 180"}
+	for _, test := range tests {
+		payloads := make(chan map[string]interface{}, 1)
+		server := recordServer(t, http.StatusNoContent, payloads)
+
+		if err := NewChat(server.URL, test.format).Send(context.Background(), event); err != nil {
+			t.Fatalf("%s: send: %s", test.format, err)
+		}
+		payload := <-payloads
+		if len(payload) != 1 || payload[test.key] != event.Text() {
+			t.Errorf("%s: got %v, want %s %q", test.format, payload, test.key, event.Text())
+		}
+	}
+}
@@ -0,0 +1,155 @@
+// Package pdt keeps a local ledger of the day trades of the last five business
+// days, so the bot can tell whether closing a position today counts as a day
+// trade before the broker flags the account as a pattern day trader.
+package pdt
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	// Limit is the amount of day trades allowed in five business days below 25.000$.
+	Limit = 3
+	// BusinessDays is the length of the rolling window of the day trades.
+	BusinessDays = 5
+
+	tradesKey = "pdt:trades"
+	openedKey = "pdt:opened:"
+	ledgerTTL = 14 * 24 * time.Hour
+
+	// PolicyHold keeps opening positions and holds overnight the ones that can't be closed.
+	PolicyHold = "hold"
+	// PolicySkip skips the entries that could not be closed the same day.
+	PolicySkip = "skip"
+)
+
+// Status is the day trading status of the account.
+type Status struct {
+	Restricted  bool
+	Used        int
+	OpenedToday int
+}
+
+// Remaining returns the day trades left in the window.
+func (s Status) Remaining() int {
+	return max(Limit-s.Used, 0)
+}
+
+// CanClose returns true if a position can be closed today, given whether it was opened
+// today, without being flagged as a pattern day trader.
+func (s Status) CanClose(opened_today bool) bool {
+	return !s.Restricted || !opened_today || s.Remaining() > 0
+}
+
+// CanOpen returns true if one more position opened today could still be closed today,
+// together with every other position opened today.
+func (s Status) CanOpen() bool {
+	return !s.Restricted || s.Remaining() > s.OpenedToday
+}
+
+// Ledger records in redis the positions opened every day and the day trades.
+type Ledger struct {
+	client *redis.Client
+	Policy string
+}
+
+// NewLedger returns a new Ledger with the given policy.
+func NewLedger(client *redis.Client, policy string) *Ledger {
+	return &Ledger{
+		client: client,
+		Policy: policy,
+	}
+}
+
+// Open records that a position in the symbol was opened in the given trading date.
+func (l *Ledger) Open(ctx context.Context, date string, symbol string) error {
+	_, err := l.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.SAdd(ctx, openedKey+date, symbol)
+		pipe.Expire(ctx, openedKey+date, ledgerTTL)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("unable to record the opening of %s: %w", symbol, err)
+	}
+	return nil
+}
+
+// Close records that the position in the symbol was closed in the given trading date.
+// If it was opened in the same date, it records a day trade and returns true.
+func (l *Ledger) Close(ctx context.Context, date string, symbol string, at time.Time) (bool, error) {
+	removed, err := l.client.SRem(ctx, openedKey+date, symbol).Result()
+	if err != nil {
+		return false, fmt.Errorf("unable to record the closing of %s: %w", symbol, err)
+	}
+	if removed == 0 {
+		return false, nil
+	}
+
+	_, err = l.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.ZAdd(ctx, tradesKey, redis.Z{
+			Score:  float64(at.Unix()),
+			Member: fmt.Sprintf("%s:%s:%d", date, symbol, at.UnixNano()),
+		})
+		pipe.ZRemRangeByScore(ctx, tradesKey, "-inf", fmt.Sprintf("(%d", at.Add(-ledgerTTL).Unix()))
+		return nil
+	})
+	if err != nil {
+		return true, fmt.Errorf("unable to record the day trade of %s: %w", symbol, err)
+	}
+	return true, nil
+}
+
+// Reconcile records as closed the positions opened in the given trading date that are
+// no longer held, like the ones closed by a stop loss at the broker.
+func (l *Ledger) Reconcile(ctx context.Context, date string, held []string) error {
+	opened, err := l.client.SMembers(ctx, openedKey+date).Result()
+	if err != nil {
+		return fmt.Errorf("unable to get the positions opened in %s: %w", date, err)
+	}
+
+	holding := make(map[string]bool, len(held))
+	for _, symbol := range held {
+		holding[symbol] = true
+	}
+	for _, symbol := range opened {
+		if holding[symbol] {
+			continue
+		}
+		if _, err := l.Close(ctx, date, symbol, time.Now()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// OpenedToday returns true if the position in the symbol was opened in the given trading
+// date, which means closing it in the same date is a day trade.
+func (l *Ledger) OpenedToday(ctx context.Context, date string, symbol string) (bool, error) {
+	opened, err := l.client.SIsMember(ctx, openedKey+date, symbol).Result()
+	if err != nil {
+		return false, fmt.Errorf("unable to check the opening of %s: %w", symbol, err)
+	}
+	return opened, nil
+}
+
+// Status returns the day trading status of the given trading date, counting the day
+// trades made since the start of the window.
+func (l *Ledger) Status(ctx context.Context, date string, since time.Time, restricted bool) (Status, error) {
+	used, err := l.client.ZCount(ctx, tradesKey, fmt.Sprint(since.Unix()), "+inf").Result()
+	if err != nil {
+		return Status{}, fmt.Errorf("unable to count the day trades: %w", err)
+	}
+	opened, err := l.client.SCard(ctx, openedKey+date).Result()
+	if err != nil {
+		return Status{}, fmt.Errorf("unable to count the positions opened in %s: %w", date, err)
+	}
+	return Status{
+		Restricted:  restricted,
+		Used:        int(used),
+		OpenedToday: int(opened),
+	}, nil
+}
@@ -0,0 +1,58 @@
+package pdt
+
+import "testing"
+
+func TestStatusRemaining(t *testing.T) {
+	tests := []struct {
+		used int
+		want int
+	}{
+		{0, 3},
+		{2, 1},
+		{3, 0},
+		{5, 0},
+	}
+	for _, test := range tests {
+		if got := (Status{Used: test.used}).Remaining(); got != test.want {
+			t.Errorf("used %d: got %d remaining, want %d", test.used, got, test.want)
+		}
+	}
+}
+
+func TestStatusCanOpen(t *testing.T) {
+	tests := []struct {
+		name   string
+		status Status
+		want   bool
+	}{
+		{"not restricted", Status{Used: 3, OpenedToday: 5}, true},
+		{"no day trades used", Status{Restricted: true}, true},
+		{"room for one more", Status{Restricted: true, Used: 1, OpenedToday: 1}, true},
+		{"every day trade taken by the opened", Status{Restricted: true, Used: 1, OpenedToday: 2}, false},
+		{"no day trades left", Status{Restricted: true, Used: 3}, false},
+	}
+	for _, test := range tests {
+		if got := test.status.CanOpen(); got != test.want {
+			t.Errorf("%s: got %v, want %v", test.name, got, test.want)
+		}
+	}
+}
+
+func TestStatusCanClose(t *testing.T) {
+	tests := []struct {
+		name         string
+		status       Status
+		opened_today bool
+		want         bool
+	}{
+		{"not restricted", Status{Used: 3}, true, true},
+		{"opened before today", Status{Restricted: true, Used: 3}, false, true},
+		{"day trade left", Status{Restricted: true, Used: 2}, true, true},
+		{"no day trades left", Status{Restricted: true, Used: 3}, true, false},
+	}
+	for _, test := range tests {
+		if got := test.status.CanClose(test.opened_today); got != test.want {
+			t.Errorf("%s: got %v, want %v", test.name, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,131 @@
+// Package position keeps track of why every position was opened and decides
+// when it should be closed, besides its stop loss.
+package position
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const entriesKey = "positions:entries"
+
+const (
+	Long  = "long"
+	Short = "short"
+)
+
+// Entry is the news that opened a position.
+type Entry struct {
+	Symbol   string    `json:"symbol"`
+	Side     string    `json:"side"`
+	NewsID   int64     `json:"news_id"`
+	Headline string    `json:"headline"`
+	Score    int       `json:"score"`
+	OpenedAt time.Time `json:"opened_at"`
+}
+
+// Exits are the exit rules of every position, the zero values are disabled.
+// A long position is closed when a headline about its symbol scores at or below
+// ReversalScore, and a short one when it scores at or above 100 - ReversalScore.
+type Exits struct {
+	MaxHold       time.Duration
+	ProfitTarget  float64
+	ReversalScore int
+}
+
+// Check returns the reason to close the position of the entry, which has been held
+// until now and has the given unrealized P&L percentage, or false if it stays open.
+func (e Exits) Check(entry Entry, now time.Time, pl_percent float64) (string, bool) {
+	if e.MaxHold > 0 && now.Sub(entry.OpenedAt) >= e.MaxHold {
+		return fmt.Sprintf("held for more than %s", e.MaxHold), true
+	}
+	if e.ProfitTarget > 0 && pl_percent >= e.ProfitTarget {
+		return fmt.Sprintf("profit of %.2f%% reached the target of %.2f%%", pl_percent*100, e.ProfitTarget*100), true
+	}
+	return "", false
+}
+
+// Reversed returns true if the score of a new headline goes against the position of the entry.
+func (e Exits) Reversed(entry Entry, score int) bool {
+	if e.ReversalScore <= 0 || score <= 0 {
+		return false
+	}
+	if entry.Side == Long {
+		return score <= e.ReversalScore
+	}
+	return score >= 100-e.ReversalScore
+}
+
+// Manager stores the entries of the open positions in redis.
+type Manager struct {
+	client *redis.Client
+	Exits  Exits
+}
+
+// NewManager returns a new Manager with the given exit rules.
+func NewManager(client *redis.Client, exits Exits) *Manager {
+	return &Manager{
+		client: client,
+		Exits:  exits,
+	}
+}
+
+// Open saves the entry of a position, replacing the previous one of the symbol.
+func (m *Manager) Open(ctx context.Context, entry Entry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("unable to marshal the entry of %s: %w", entry.Symbol, err)
+	}
+	if err := m.client.HSet(ctx, entriesKey, entry.Symbol, data).Err(); err != nil {
+		return fmt.Errorf("unable to save the entry of %s: %w", entry.Symbol, err)
+	}
+	return nil
+}
+
+// Get returns the entry of the position of the symbol. It returns nil and nil
+// if there is none.
+func (m *Manager) Get(ctx context.Context, symbol string) (*Entry, error) {
+	data, err := m.client.HGet(ctx, entriesKey, symbol).Bytes()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to get the entry of %s: %w", symbol, err)
+	}
+
+	var entry Entry
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return nil, fmt.Errorf("unable to parse the entry of %s: %w", symbol, err)
+	}
+	return &entry, nil
+}
+
+// Entries returns the entries of every position.
+func (m *Manager) Entries(ctx context.Context) ([]Entry, error) {
+	values, err := m.client.HGetAll(ctx, entriesKey).Result()
+	if err != nil {
+		return nil, fmt.Errorf("unable to get the entries: %w", err)
+	}
+
+	entries := make([]Entry, 0, len(values))
+	for symbol, value := range values {
+		var entry Entry
+		if err := json.Unmarshal([]byte(value), &entry); err != nil {
+			return nil, fmt.Errorf("unable to parse the entry of %s: %w", symbol, err)
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// Close deletes the entry of the position of the symbol.
+func (m *Manager) Close(ctx context.Context, symbol string) error {
+	if err := m.client.HDel(ctx, entriesKey, symbol).Err(); err != nil {
+		return fmt.Errorf("unable to delete the entry of %s: %w", symbol, err)
+	}
+	return nil
+}
@@ -0,0 +1,337 @@
+// Package risk defines the risk profiles, which drive when the bot trades,
+// how much it buys or sells and how far the stop losses are.
+package risk
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/jmvdr-iscte/TradingBotCli/enums"
+)
+
+// Band is a sizing band. For buys it applies to the scores at or above Score,
+// for sells to the scores at or below Score. The quantity is Percent of the
+// buying power, limited by Cap shares and at least Floor shares, when they are set.
+type Band struct {
+	Score   int     `json:"score"`
+	Percent float64 `json:"percent"`
+	Cap     float64 `json:"cap"`
+	Floor   float64 `json:"floor"`
+}
+
+const (
+	// SizingBands sizes the orders with a percentage of the buying power.
+	SizingBands = "bands"
+	// SizingVolatility sizes the orders so the loss at the stop is a fixed fraction of the equity.
+	SizingVolatility = "volatility"
+
+	// VolatilityATR measures the volatility with the average true range.
+	VolatilityATR = "atr"
+	// VolatilityRealized measures the volatility with the standard deviation of the returns.
+	VolatilityRealized = "realized"
+
+	// FractionalNone only trades whole shares.
+	FractionalNone = "none"
+	// FractionalQty trades fractional quantities of the fractionable assets.
+	FractionalQty = "qty"
+	// FractionalNotional buys the fractionable assets by dollar amount.
+	FractionalNotional = "notional"
+)
+
+// Volatility is the config of the volatility sizing. The stop is placed Multiple
+// times the volatility of the last Period daily bars away from the entry, and the
+// quantity is chosen so hitting the stop loses RiskPerTrade of the equity.
+type Volatility struct {
+	Method       string  `json:"method"`
+	Period       int     `json:"period"`
+	Multiple     float64 `json:"multiple"`
+	RiskPerTrade float64 `json:"risk_per_trade"`
+}
+
+// Overnight is the config of the positions carried overnight. At the close the positions
+// are trimmed to MaxPositions and to MaxExposure of the equity, and before the next session
+// the ones that gapped more than MaxGap against them are closed.
+type Overnight struct {
+	Enabled      bool    `json:"enabled"`
+	MaxExposure  float64 `json:"max_exposure"`
+	MaxPositions int     `json:"max_positions"`
+	MaxGap       float64 `json:"max_gap"`
+}
+
+// Leverage is the config of the intraday leverage of the accounts above the pattern day
+// trader equity. The orders are sized from the day trading buying power, limited so the
+// gross exposure stays under MaxLeverage times the equity and the maintenance margin under
+// MaxMaintenance of the equity.
+type Leverage struct {
+	Enabled        bool    `json:"enabled"`
+	MaxLeverage    float64 `json:"max_leverage"`
+	MaxMaintenance float64 `json:"max_maintenance"`
+}
+
+// Profile is a named risk profile.
+type Profile struct {
+	Name         string     `json:"name"`
+	HighLimit    int        `json:"high_limit"`
+	LowLimit     int        `json:"low_limit"`
+	Sizing       string     `json:"sizing"`
+	Multiplier   float64    `json:"multiplier"`
+	BuyBands     []Band     `json:"buy_bands"`
+	SellBands    []Band     `json:"sell_bands"`
+	Volatility   Volatility `json:"volatility"`
+	StopDistance float64    `json:"stop_distance"`
+	MaxPositions int        `json:"max_positions"`
+	Fractional   string     `json:"fractional"`
+	Overnight    Overnight  `json:"overnight"`
+	Leverage     Leverage   `json:"leverage"`
+}
+
+// Profiles are the risk profiles available, by name.
+type Profiles map[string]Profile
+
+// defaultOvernight are the overnight limits of the profiles that don't declare them.
+var defaultOvernight = Overnight{
+	MaxExposure:  0.5,
+	MaxPositions: 5,
+	MaxGap:       0.05,
+}
+
+// defaultMaxMaintenance is the maintenance margin limit of the profiles with leverage
+// that don't declare it, as a fraction of the equity.
+const defaultMaxMaintenance = 0.5
+
+// pdtBuyBands and pdtSellBands scale the size of the order with the strength of the score.
+var (
+	pdtBuyBands = []Band{
+		{Score: 95, Percent: 0.10, Cap: 20},
+		{Score: 90, Percent: 0.07, Cap: 14},
+		{Score: 80, Percent: 0.05, Cap: 10},
+		{Score: 0, Percent: 0.02, Cap: 4},
+	}
+	pdtSellBands = []Band{
+		{Score: 5, Percent: 0.10, Cap: 20},
+		{Score: 10, Percent: 0.07, Cap: 14},
+		{Score: 20, Percent: 0.05, Cap: 10},
+		{Score: 100, Percent: 0.02, Cap: 4},
+	}
+)
+
+// Defaults returns the five profiles shipped with the bot, one for each enums.Risk.
+func Defaults() Profiles {
+	// The day trading profiles ship with their leverage disabled, a profiles file opts in.
+	pdt := func(risk enums.Risk, multiplier float64, leverage float64) Profile {
+		return Profile{
+			Name:         risk.String(),
+			HighLimit:    75,
+			LowLimit:     25,
+			Sizing:       SizingBands,
+			Multiplier:   multiplier,
+			BuyBands:     pdtBuyBands,
+			SellBands:    pdtSellBands,
+			StopDistance: 0.10,
+			Fractional:   FractionalQty,
+			Leverage:     Leverage{MaxLeverage: leverage, MaxMaintenance: defaultMaxMaintenance},
+		}
+	}
+	// The flat profiles trade rarely, so they may carry their positions overnight.
+	flat := func(risk enums.Risk, band Band) Profile {
+		return Profile{
+			Name:         risk.String(),
+			HighLimit:    95,
+			LowLimit:     5,
+			Sizing:       SizingBands,
+			Multiplier:   1,
+			BuyBands:     []Band{band},
+			SellBands:    []Band{band},
+			StopDistance: 0.10,
+			Fractional:   FractionalQty,
+			Overnight:    defaultOvernight,
+		}
+	}
+
+	return Profiles{
+		enums.Safe.String():   flat(enums.Safe, Band{Score: 0, Percent: 0.10, Cap: 20}),
+		enums.Low.String():    pdt(enums.Low, 0.5, 1.5),
+		enums.Medium.String(): pdt(enums.Medium, 1.0, 2),
+		enums.High.String():   pdt(enums.High, 2.0, 3),
+		enums.Power.String():  flat(enums.Power, Band{Score: 0, Percent: 0.10, Floor: 20}),
+	}
+}
+
+// Load returns the default profiles plus the ones declared in the given json file.
+// A profile in the file with the name of a default one replaces it. If the path is
+// empty only the defaults are returned. It returns an error if a profile is invalid.
+func Load(path string) (Profiles, error) {
+	profiles := Defaults()
+	if path == "" {
+		return profiles, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read risk profiles file: %w", err)
+	}
+
+	var custom []Profile
+	if err := json.Unmarshal(data, &custom); err != nil {
+		return nil, fmt.Errorf("unable to parse risk profiles file: %w", err)
+	}
+
+	for _, profile := range custom {
+		profile.Name = strings.ToLower(strings.TrimSpace(profile.Name))
+		if err := profile.validate(); err != nil {
+			return nil, fmt.Errorf("risk profile %q: %w", profile.Name, err)
+		}
+		profiles[profile.Name] = profile
+	}
+	return profiles, nil
+}
+
+// Get returns the profile with the given name, it returns an error if there is none.
+func (p Profiles) Get(name string) (Profile, error) {
+	profile, exists := p[strings.ToLower(strings.TrimSpace(name))]
+	if !exists {
+		return Profile{}, fmt.Errorf("unknown risk profile: %s", name)
+	}
+	return profile, nil
+}
+
+// Names returns the names of the profiles sorted alphabetically.
+func (p Profiles) Names() []string {
+	names := make([]string, 0, len(p))
+	for name := range p {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// EnableOvernight lets the profiles with the given names hold their positions overnight,
+// with the default limits where they declare none. It returns an error if a profile does
+// not exist.
+func (p Profiles) EnableOvernight(names []string) error {
+	for _, name := range names {
+		profile, err := p.Get(name)
+		if err != nil {
+			return err
+		}
+		profile.Overnight.Enabled = true
+		profile.Overnight.setDefaults()
+		p[profile.Name] = profile
+	}
+	return nil
+}
+
+// setDefaults sets the limits that are not declared to the ones of defaultOvernight.
+func (o *Overnight) setDefaults() {
+	if o.MaxExposure == 0 {
+		o.MaxExposure = defaultOvernight.MaxExposure
+	}
+	if o.MaxGap == 0 {
+		o.MaxGap = defaultOvernight.MaxGap
+	}
+}
+
+// BuyBand returns the band that sizes a buy with the given score.
+func (p Profile) BuyBand(score int) (Band, bool) {
+	for _, band := range p.BuyBands {
+		if score >= band.Score {
+			return band, true
+		}
+	}
+	return Band{}, false
+}
+
+// SellBand returns the band that sizes a sell with the given score.
+func (p Profile) SellBand(score int) (Band, bool) {
+	for _, band := range p.SellBands {
+		if score <= band.Score {
+			return band, true
+		}
+	}
+	return Band{}, false
+}
+
+// validate returns an error if the profile can't be used to trade.
+func (p *Profile) validate() error {
+	if p.Name == "" {
+		return fmt.Errorf("missing name")
+	}
+	if p.LowLimit < 0 || p.HighLimit > 100 || p.LowLimit >= p.HighLimit {
+		return fmt.Errorf("limits must satisfy 0 <= low_limit < high_limit <= 100")
+	}
+	switch p.Sizing {
+	case "", SizingBands:
+		p.Sizing = SizingBands
+		if len(p.BuyBands) == 0 || len(p.SellBands) == 0 {
+			return fmt.Errorf("missing buy or sell bands")
+		}
+	case SizingVolatility:
+		if err := p.Volatility.validate(); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("unknown sizing %q", p.Sizing)
+	}
+	// The volatility sizing falls back to the stop distance when there are no bars.
+	if p.StopDistance <= 0 || p.StopDistance >= 1 {
+		return fmt.Errorf("stop_distance must be between 0 and 1")
+	}
+	if p.Multiplier == 0 {
+		p.Multiplier = 1
+	}
+	switch p.Fractional {
+	case "":
+		p.Fractional = FractionalQty
+	case FractionalNone, FractionalQty, FractionalNotional:
+	default:
+		return fmt.Errorf("unknown fractional mode %q", p.Fractional)
+	}
+
+	if p.Overnight.MaxExposure < 0 || p.Overnight.MaxGap < 0 || p.Overnight.MaxPositions < 0 {
+		return fmt.Errorf("overnight limits can't be negative")
+	}
+	p.Overnight.setDefaults()
+
+	if p.Leverage.Enabled {
+		if p.Leverage.MaxLeverage == 0 {
+			p.Leverage.MaxLeverage = 1
+		}
+		if p.Leverage.MaxMaintenance == 0 {
+			p.Leverage.MaxMaintenance = defaultMaxMaintenance
+		}
+		if p.Leverage.MaxLeverage < 1 || p.Leverage.MaxMaintenance < 0 || p.Leverage.MaxMaintenance > 1 {
+			return fmt.Errorf("leverage must satisfy max_leverage >= 1 and 0 < max_maintenance <= 1")
+		}
+	}
+
+	// The first matching band is used, so the strongest scores go first.
+	sort.SliceStable(p.BuyBands, func(i, j int) bool { return p.BuyBands[i].Score > p.BuyBands[j].Score })
+	sort.SliceStable(p.SellBands, func(i, j int) bool { return p.SellBands[i].Score < p.SellBands[j].Score })
+	return nil
+}
+
+// validate returns an error if the volatility sizing can't be used, and fills the defaults.
+func (v *Volatility) validate() error {
+	if v.Method == "" {
+		v.Method = VolatilityATR
+	}
+	if v.Method != VolatilityATR && v.Method != VolatilityRealized {
+		return fmt.Errorf("unknown volatility method %q", v.Method)
+	}
+	if v.Period == 0 {
+		v.Period = 14
+	}
+	if v.Multiple == 0 {
+		v.Multiple = 2
+	}
+	if v.Period < 2 || v.Multiple < 0 {
+		return fmt.Errorf("volatility period must be at least 2 and multiple positive")
+	}
+	if v.RiskPerTrade <= 0 || v.RiskPerTrade >= 1 {
+		return fmt.Errorf("volatility risk_per_trade must be between 0 and 1")
+	}
+	return nil
+}
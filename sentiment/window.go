@@ -0,0 +1,128 @@
+// Package sentiment aggregates the sentiment scores of a symbol over a time
+// window, so a single noisy headline does not trigger a trade on its own.
+package sentiment
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const keyPrefix = "sentiment:"
+
+// Sample is a score given to a headline about the symbol.
+type Sample struct {
+	Score int
+	At    time.Time
+}
+
+// Confidence returns how far the score is from neutral, from 0 for 50 to 1 for 0 or 100.
+func Confidence(score int) float64 {
+	return math.Abs(float64(score)-50) / 50
+}
+
+// Aggregate returns the average of the scores weighted by their confidence and by
+// their recency, a score loses half of its weight every halfLife. If no sample has
+// weight it returns 50, the neutral score.
+func Aggregate(samples []Sample, now time.Time, halfLife time.Duration) float64 {
+	var total, weights float64
+	for _, sample := range samples {
+		weight := Confidence(sample.Score)
+		if halfLife > 0 {
+			weight *= math.Pow(0.5, now.Sub(sample.At).Seconds()/halfLife.Seconds())
+		}
+		total += weight * float64(sample.Score)
+		weights += weight
+	}
+	if weights == 0 {
+		return 50
+	}
+	return total / weights
+}
+
+// Window keeps the scores of every symbol in redis for Span.
+type Window struct {
+	client   *redis.Client
+	Span     time.Duration
+	HalfLife time.Duration
+	Min      int
+}
+
+// NewWindow returns a new Window that keeps the scores for span, with the given
+// half life, and needs at least min scores to aggregate them.
+func NewWindow(client *redis.Client, span time.Duration, halfLife time.Duration, min int) *Window {
+	return &Window{
+		client:   client,
+		Span:     span,
+		HalfLife: halfLife,
+		Min:      min,
+	}
+}
+
+// Add adds the score of a headline about the symbol and returns the aggregate of the
+// window and the amount of scores in it.
+func (w *Window) Add(ctx context.Context, symbol string, score int, at time.Time) (float64, int, error) {
+	key := keyPrefix + symbol
+	since := at.Add(-w.Span).UnixMilli()
+
+	var entries *redis.StringSliceCmd
+	_, err := w.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.ZAdd(ctx, key, redis.Z{
+			Score:  float64(at.UnixMilli()),
+			Member: fmt.Sprintf("%d:%d", at.UnixNano(), score),
+		})
+		pipe.ZRemRangeByScore(ctx, key, "-inf", "("+strconv.FormatInt(since, 10))
+		entries = pipe.ZRange(ctx, key, 0, -1)
+		pipe.Expire(ctx, key, w.Span)
+		return nil
+	})
+	if err != nil {
+		return 0, 0, fmt.Errorf("unable to add the score of %s: %w", symbol, err)
+	}
+
+	samples := make([]Sample, 0, len(entries.Val()))
+	for _, entry := range entries.Val() {
+		sample, err := parseSample(entry)
+		if err != nil {
+			return 0, 0, err
+		}
+		samples = append(samples, sample)
+	}
+	return Aggregate(samples, at, w.HalfLife), len(samples), nil
+}
+
+// Cross records the zone the aggregate of the symbol is in, hold, buy or sell, and
+// returns true if it just entered it. The aggregate only triggers a trade when it
+// crosses a threshold, not while it stays beyond it.
+func (w *Window) Cross(ctx context.Context, symbol string, zone string) (bool, error) {
+	previous, err := w.client.SetArgs(ctx, keyPrefix+symbol+":zone", zone, redis.SetArgs{
+		Get: true,
+		TTL: w.Span,
+	}).Result()
+	if err != nil && err != redis.Nil {
+		return false, fmt.Errorf("unable to set the zone of %s: %w", symbol, err)
+	}
+	return previous != zone, nil
+}
+
+// parseSample parses a sample stored as <unix nano>:<score>.
+func parseSample(entry string) (Sample, error) {
+	at, score, found := strings.Cut(entry, ":")
+	if !found {
+		return Sample{}, fmt.Errorf("invalid sentiment sample %q", entry)
+	}
+	nanos, err := strconv.ParseInt(at, 10, 64)
+	if err != nil {
+		return Sample{}, fmt.Errorf("invalid sentiment sample %q: %w", entry, err)
+	}
+	value, err := strconv.Atoi(score)
+	if err != nil {
+		return Sample{}, fmt.Errorf("invalid sentiment sample %q: %w", entry, err)
+	}
+	return Sample{Score: value, At: time.Unix(0, nanos)}, nil
+}
@@ -0,0 +1,237 @@
+// Package server is used to contain the app server.
+package server
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/hibiken/asynq"
+	"github.com/jmvdr-iscte/TradingBotCli/approval"
+	"github.com/jmvdr-iscte/TradingBotCli/logger"
+	"github.com/jmvdr-iscte/TradingBotCli/worker"
+	"github.com/rs/zerolog/log"
+)
+
+// ApprovalAPI approves and rejects the decisions waiting for approval, from the CLI,
+// over HTTP or from a chat webhook. The approved decisions are queued for the workers.
+type ApprovalAPI struct {
+	Approvals   *approval.Store
+	Distributor worker.TaskDistributor
+	Token       string
+}
+
+// Approve queues the decision with the given id to be traded. It returns
+// approval.ErrNotFound if the decision is not pending. If it can't be queued the
+// decision is parked again, so it can still be approved.
+func (api *ApprovalAPI) Approve(ctx context.Context, id string) (approval.Decision, error) {
+	decision, err := api.Approvals.Take(ctx, id)
+	if err != nil {
+		return decision, err
+	}
+	err = api.Distributor.DistributeTaskApprovedOrder(ctx, &decision, asynq.Queue(worker.QueueCritical), asynq.MaxRetry(1))
+	if err != nil {
+		if err := api.Approvals.Restore(ctx, decision); err != nil {
+			log.Error().Err(err).Str("decision", decision.ID).Msg("unable to park the decision again")
+		}
+		return decision, fmt.Errorf("unable to queue the approved decision: %w", err)
+	}
+	log.Info().Str("decision", decision.ID).Str(logger.Symbol, decision.Symbol).Int64(logger.NewsID, decision.Message.ID).
+		Msg("decision approved")
+	return decision, nil
+}
+
+// Reject discards the decision with the given id. It returns approval.ErrNotFound if the
+// decision is not pending.
+func (api *ApprovalAPI) Reject(ctx context.Context, id string) (approval.Decision, error) {
+	decision, err := api.Approvals.Take(ctx, id)
+	if err != nil {
+		return decision, err
+	}
+	log.Info().Str("decision", decision.ID).Str(logger.Symbol, decision.Symbol).Int64(logger.NewsID, decision.Message.ID).
+		Msg("decision rejected")
+	return decision, nil
+}
+
+// Command runs a text command, `pending`, `approve <id>` or `reject <id>`, and returns
+// the reply. It is used by the CLI and the chat webhook.
+func (api *ApprovalAPI) Command(ctx context.Context, text string) string {
+	fields := strings.Fields(text)
+	if len(fields) == 0 {
+		return "usage: pending | approve <id> | reject <id>"
+	}
+
+	switch {
+	case fields[0] == "pending":
+		decisions, err := api.Approvals.Pending(ctx)
+		if err != nil {
+			return err.Error()
+		}
+		if len(decisions) == 0 {
+			return "no decisions waiting for approval"
+		}
+		lines := make([]string, len(decisions))
+		for i, decision := range decisions {
+			lines[i] = decision.String()
+		}
+		return strings.Join(lines, "\n")
+
+	case fields[0] == "approve" && len(fields) == 2:
+		decision, err := api.Approve(ctx, fields[1])
+		if err != nil {
+			return err.Error()
+		}
+		return fmt.Sprintf("approved %s %s", decision.Side, decision.Symbol)
+
+	case fields[0] == "reject" && len(fields) == 2:
+		decision, err := api.Reject(ctx, fields[1])
+		if err != nil {
+			return err.Error()
+		}
+		return fmt.Sprintf("rejected %s %s", decision.Side, decision.Symbol)
+	}
+	return "usage: pending | approve <id> | reject <id>"
+}
+
+// Handler returns the HTTP handler of the API:
+//
+//	GET  /approvals               the pending decisions
+//	POST /approvals/<id>/approve  approves a decision
+//	POST /approvals/<id>/reject   rejects a decision
+//	POST /approvals/chat          runs a command from a Slack or Discord compatible webhook
+func (api *ApprovalAPI) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/approvals", api.authorized(api.handlePending))
+	mux.HandleFunc("/approvals/chat", api.authorized(api.handleChat))
+	mux.HandleFunc("/approvals/", api.authorized(api.handleDecision))
+	return mux
+}
+
+// ListenAndServe serves the API on the given address until the context is done.
+func (api *ApprovalAPI) ListenAndServe(ctx context.Context, addr string) error {
+	server := &http.Server{
+		Addr:              addr,
+		Handler:           api.Handler(),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	go func() {
+		<-ctx.Done()
+		shutdown_ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(shutdown_ctx)
+	}()
+
+	if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return fmt.Errorf("unable to serve the approvals: %w", err)
+	}
+	return nil
+}
+
+// handlePending writes the pending decisions.
+func (api *ApprovalAPI) handlePending(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	decisions, err := api.Approvals.Pending(r.Context())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, decisions)
+}
+
+// handleDecision approves or rejects the decision of the path.
+func (api *ApprovalAPI) handleDecision(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/approvals/"), "/")
+	if len(parts) != 2 {
+		http.NotFound(w, r)
+		return
+	}
+
+	var (
+		decision approval.Decision
+		err      error
+	)
+	switch parts[1] {
+	case "approve":
+		decision, err = api.Approve(r.Context(), parts[0])
+	case "reject":
+		decision, err = api.Reject(r.Context(), parts[0])
+	default:
+		http.NotFound(w, r)
+		return
+	}
+
+	if errors.Is(err, approval.ErrNotFound) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	} else if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, decision)
+}
+
+// handleChat runs the command sent by a chat webhook, either as the text field of a form,
+// like the Slack slash commands, or as the text or content field of a json body.
+func (api *ApprovalAPI) handleChat(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var text string
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "application/json") {
+		var body struct {
+			Text    string `json:"text"`
+			Content string `json:"content"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			http.Error(w, "invalid json", http.StatusBadRequest)
+			return
+		}
+		text = body.Text + body.Content
+	} else {
+		text = r.FormValue("text")
+	}
+
+	reply := api.Command(r.Context(), text)
+	writeJSON(w, http.StatusOK, map[string]string{"text": reply, "content": reply})
+}
+
+// authorized only lets through the requests with the token of the API, as a bearer token
+// or as the token field, when the API has one.
+func (api *ApprovalAPI) authorized(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if api.Token != "" {
+			token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+			if token == "" {
+				token = r.FormValue("token")
+			}
+			if subtle.ConstantTimeCompare([]byte(token), []byte(api.Token)) != 1 {
+				http.Error(w, "unauthorized", http.StatusUnauthorized)
+				return
+			}
+		}
+		next(w, r)
+	}
+}
+
+// writeJSON writes the value as the json body of the response.
+func writeJSON(w http.ResponseWriter, status int, value interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(value); err != nil {
+		log.Error().Err(err).Msg("failed to write the response")
+	}
+}
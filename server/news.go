@@ -2,12 +2,21 @@
 package server
 
 import (
-	"log"
+	"context"
+	"fmt"
+	"slices"
 	"sync"
+	"time"
 
 	"github.com/jmvdr-iscte/TradingBotCli/alpaca"
 	"github.com/jmvdr-iscte/TradingBotCli/models"
+	"github.com/jmvdr-iscte/TradingBotCli/notify"
+	"github.com/jmvdr-iscte/TradingBotCli/pdt"
+	"github.com/jmvdr-iscte/TradingBotCli/position"
+	"github.com/jmvdr-iscte/TradingBotCli/risk"
+	"github.com/jmvdr-iscte/TradingBotCli/session"
 	"github.com/jmvdr-iscte/TradingBotCli/worker"
+	"github.com/rs/zerolog/log"
 	"golang.org/x/net/websocket"
 )
 
@@ -16,28 +25,44 @@ type NewsServer struct {
 	Conns            map[*websocket.Conn]bool
 	Mu               sync.Mutex
 	shutdownCh       chan struct{}
+	done             chan struct{}
 	Options          models.Options
 	Task_distributor worker.TaskDistributor
 	AlpacaClient     *alpaca.AlpacaClient
+	StartedAt        time.Time
+	Session          *session.Store
+	Fence            int64
+	Positions        *position.Manager
+	Profile          risk.Profile
+	Ledger           *pdt.Ledger
+	Notifier         *notify.Notifier
+	MaxLoss          float64
 }
 
 // NewsServer instanciates a pointer of a new server with the correct run options and task distributors.
-func NewServer(task_distributor worker.TaskDistributor, options *models.Options) *NewsServer {
+// If the options already have a starting value, restored from the session, it is kept.
+func NewServer(task_distributor worker.TaskDistributor, options *models.Options, store *session.Store) *NewsServer {
 	alpaca_client := *alpaca.LoadClient()
-	var err error
-	options.StartingValue, err = alpaca_client.GetEquity()
-	if err != nil {
-		log.Fatalf("Failed to get equity: %v", err)
-		return nil
+	alpaca_client.SetDryRun(options.DryRun)
+	if options.StartingValue == 0 {
+		var err error
+		options.StartingValue, err = alpaca_client.GetEquity()
+		if err != nil {
+			log.Fatal().Err(err).Msg("failed to get equity")
+			return nil
+		}
 	}
 
 	server := &NewsServer{
 		Conns:            make(map[*websocket.Conn]bool),
 		Mu:               sync.Mutex{},
 		shutdownCh:       make(chan struct{}),
+		done:             make(chan struct{}),
 		Task_distributor: task_distributor,
 		Options:          *options,
 		AlpacaClient:     &alpaca_client,
+		StartedAt:        time.Now(),
+		Session:          store,
 	}
 
 	go func() {
@@ -64,5 +89,78 @@ func (s *NewsServer) Shutdown() {
 	}
 
 	close(s.shutdownCh)
+	close(s.done)
 	s.shutdownCh = nil
 }
+
+// Done returns a channel that is closed when the server shuts down.
+func (s *NewsServer) Done() <-chan struct{} {
+	return s.done
+}
+
+// RecordRealizedPnL returns an error if it was not able to persist the realized P&L of
+// the session. The realized P&L is the equity gained since the start of the day that
+// is not tied to an open position.
+func (s *NewsServer) RecordRealizedPnL(current_equity float64) error {
+	unrealized, err := s.AlpacaClient.GetUnrealizedPL()
+	if err != nil {
+		return err
+	}
+	realized := current_equity - s.Options.StartingValue - unrealized
+	return s.Session.SetRealizedPnL(context.Background(), session.TradingDate(time.Now()), realized)
+}
+
+// Flatten closes every position that can be closed without going over the day trade
+// limit, and deletes the entries of the positions it closed. The positions held overnight
+// keep their entries.
+func (s *NewsServer) Flatten(ctx context.Context) error {
+	held, err := s.AlpacaClient.ClosePositionsWithin(ctx, s.Ledger, s.Profile.StopDistance)
+	if err != nil || s.Positions == nil {
+		return err
+	}
+
+	entries, err := s.Positions.Entries(ctx)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if slices.Contains(held, entry.Symbol) {
+			continue
+		}
+		if err := s.Positions.Close(ctx, entry.Symbol); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Summary returns the report of the current session, with the trades made since the
+// server started and the P&L against the starting value. It returns an error if it is
+// not able to reach the Alpaca API.
+func (s *NewsServer) Summary() (*models.Summary, error) {
+	equity, err := s.AlpacaClient.GetEquity()
+	if err != nil {
+		return nil, fmt.Errorf("unable to get equity: %w", err)
+	}
+
+	orders, err := s.AlpacaClient.GetFilledOrders(s.StartedAt)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get orders: %w", err)
+	}
+
+	summary := &models.Summary{
+		StartedAt:     s.StartedAt,
+		StartingValue: s.Options.StartingValue,
+		Equity:        equity,
+		PnL:           equity - s.Options.StartingValue,
+		Trades:        len(orders),
+	}
+	for _, order := range orders {
+		if order.Side == "buy" {
+			summary.Buys++
+		} else {
+			summary.Sells++
+		}
+	}
+	return summary, nil
+}
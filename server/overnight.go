@@ -0,0 +1,85 @@
+// Package server is used to contain the app server.
+package server
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/hibiken/asynq"
+	"github.com/jmvdr-iscte/TradingBotCli/logger"
+	"github.com/jmvdr-iscte/TradingBotCli/session"
+	"github.com/jmvdr-iscte/TradingBotCli/worker"
+	"github.com/rs/zerolog/log"
+)
+
+// ReviewOvernight reviews the positions carried from the previous session, once per
+// trading day. The positions whose price gapped more than the max gap against them are
+// closed, and the headlines published about the others since they were opened are queued
+// like the live news, so the exit rules and the signal policy apply to them.
+func (s *NewsServer) ReviewOvernight() error {
+	ctx := context.Background()
+	// The holdings are fetched first so a failed fetch is retried on the next tick.
+	holdings, err := s.AlpacaClient.GetHoldings()
+	if err != nil {
+		return err
+	}
+
+	reviewed, err := s.Session.MarkReviewed(ctx, session.TradingDate(time.Now()))
+	if err != nil || !reviewed {
+		return err
+	}
+
+	for _, holding := range holdings {
+		holding_log := log.With().Str(logger.Symbol, holding.Symbol).Logger()
+		gap, err := s.AlpacaClient.GetGap(holding.Symbol)
+		if err != nil {
+			holding_log.Error().Err(err).Msg("unable to get the gap")
+			continue
+		}
+
+		against := -gap
+		if holding.Qty < 0 {
+			against = gap
+		}
+		if against > s.Profile.Overnight.MaxGap {
+			holding_log.Warn().Float64("gap_pct", gap*100).Msg("gapped against the position, closing it")
+			if err := s.AlpacaClient.ClosePosition(holding.Symbol); err != nil {
+				return err
+			}
+			if s.Positions != nil {
+				if err := s.Positions.Close(ctx, holding.Symbol); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+
+		since := time.Now().Add(-24 * time.Hour)
+		if s.Positions != nil {
+			entry, err := s.Positions.Get(ctx, holding.Symbol)
+			if err != nil {
+				return err
+			}
+			if entry != nil {
+				since = entry.OpenedAt.Add(time.Second)
+			}
+		}
+
+		news, err := s.AlpacaClient.GetNews(holding.Symbol, since)
+		if err != nil {
+			holding_log.Error().Err(err).Msg("unable to get the news")
+			continue
+		}
+		holding_log.Info().Float64("gap_pct", gap*100).Int("headlines", len(news)).Msg("reviewing the position overnight")
+		for _, message := range news {
+			message.Risk = s.Options.Risk
+			message.Fence = s.Fence
+			err := s.Task_distributor.DistributeTaskProcessOrder(ctx, &message, asynq.Queue(worker.QueueDefault), asynq.MaxRetry(1))
+			if err != nil {
+				return fmt.Errorf("unable to distribute task %w", err)
+			}
+		}
+	}
+	return nil
+}
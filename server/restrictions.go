@@ -0,0 +1,45 @@
+// Package server is used to contain the app server.
+package server
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jmvdr-iscte/TradingBotCli/notify"
+	"github.com/rs/zerolog/log"
+)
+
+// CheckRestrictions returns true if the account can't trade. The trading of every instance
+// is halted with the reason of the restriction and an alert is emitted, and it is resumed
+// by the first check that finds the account without restrictions.
+func (s *NewsServer) CheckRestrictions() (bool, error) {
+	ctx := context.Background()
+	restriction, err := s.AlpacaClient.GetRestriction()
+	if err != nil {
+		return false, fmt.Errorf("unable to check the account restrictions: %w", err)
+	}
+
+	halted, err := s.Session.Halted(ctx)
+	if err != nil {
+		return false, err
+	}
+	if restriction == "" {
+		if halted != "" {
+			log.Info().Str("reason", halted).Msg("the account restriction was lifted, resuming the trading")
+			return false, s.Session.Resume(ctx)
+		}
+		return false, nil
+	}
+
+	if halted != restriction {
+		s.Alert(fmt.Sprintf("trading halted: %s", restriction))
+	}
+	return true, s.Session.Halt(ctx, restriction)
+}
+
+// Alert emits an alert that needs the attention of the user, it is logged and notified
+// as an error.
+func (s *NewsServer) Alert(message string) {
+	log.Error().Str("alert", message).Msg(message)
+	s.Notifier.Notify(notify.Error, "", message)
+}
@@ -0,0 +1,398 @@
+// Package session persists the state of the trading day in redis.
+package session
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+	_ "time/tzdata" // the container image does not ship the timezone database
+
+	"github.com/jmvdr-iscte/TradingBotCli/models"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	keyPrefix  = "session:"
+	sessionTTL = 36 * time.Hour
+	maxSkips   = 500
+	// lockTTL covers the longest handling of a signal: a reversal with two limit
+	// orders repriced up to the max slippage and the wait for the close to fill.
+	lockTTL = 5 * time.Minute
+
+	// haltTTL lets the halt expire if no instance checks the account restrictions anymore,
+	// every check while the account is restricted sets it again.
+	haltTTL = 10 * time.Minute
+
+	softStopsKey = "stops:soft"
+	haltKey      = "trading:halt"
+	pausedKey    = "trading:paused"
+	maxNews      = 100
+)
+
+// unlockScript deletes the lock only if it is still held with the given token.
+var unlockScript = redis.NewScript(`
+if redis.call("get", KEYS[1]) == ARGV[1] then
+	return redis.call("del", KEYS[1])
+end
+return 0`)
+
+var marketLocation, _ = time.LoadLocation("America/New_York")
+
+// TradingDate returns the date of the trading day of the given time,
+// in the market timezone.
+func TradingDate(t time.Time) string {
+	return t.In(marketLocation).Format(time.DateOnly)
+}
+
+// Store reads and writes the sessions in redis.
+type Store struct {
+	client *redis.Client
+}
+
+// NewStore returns a new Store that uses the given redis client.
+func NewStore(client *redis.Client) *Store {
+	return &Store{
+		client: client,
+	}
+}
+
+// Load returns the session of the given trading date. It returns nil and nil
+// if there is no session for that date, and an error if redis could not be reached.
+func (store *Store) Load(ctx context.Context, date string) (*models.Session, error) {
+	cmd := store.client.HGetAll(ctx, keyPrefix+date)
+	if err := cmd.Err(); err != nil {
+		return nil, fmt.Errorf("unable to load session %s: %w", date, err)
+	}
+
+	if len(cmd.Val()) == 0 {
+		return nil, nil
+	}
+
+	var session models.Session
+	if err := cmd.Scan(&session); err != nil {
+		return nil, fmt.Errorf("unable to read session %s: %w", date, err)
+	}
+	return &session, nil
+}
+
+// Save writes the whole session, it returns an error if redis could not be reached.
+func (store *Store) Save(ctx context.Context, session *models.Session) error {
+	key := keyPrefix + session.Date
+	_, err := store.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HSet(ctx, key, map[string]interface{}{
+			"date":           session.Date,
+			"starting_value": session.StartingValue,
+			"realized_pnl":   session.RealizedPnL,
+			"trades":         session.Trades,
+			"risk":           session.Risk,
+			"gain":           session.Gain,
+		})
+		pipe.Expire(ctx, key, sessionTTL)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("unable to save session %s: %w", session.Date, err)
+	}
+	return nil
+}
+
+// AddTrade increments the amount of trades taken in the given trading date.
+func (store *Store) AddTrade(ctx context.Context, date string) error {
+	_, err := store.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HIncrBy(ctx, keyPrefix+date, "trades", 1)
+		pipe.Expire(ctx, keyPrefix+date, sessionTTL)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("unable to add trade to session %s: %w", date, err)
+	}
+	return nil
+}
+
+// SetRealizedPnL updates the realized P&L of the given trading date.
+func (store *Store) SetRealizedPnL(ctx context.Context, date string, pnl float64) error {
+	_, err := store.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HSet(ctx, keyPrefix+date, "realized_pnl", pnl)
+		pipe.Expire(ctx, keyPrefix+date, sessionTTL)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("unable to set the realized P&L of session %s: %w", date, err)
+	}
+	return nil
+}
+
+// AddSkip records the reason why a trade was skipped in the given trading date.
+// Only the latest skips are kept.
+func (store *Store) AddSkip(ctx context.Context, date string, reason string) error {
+	key := keyPrefix + date + ":skips"
+	_, err := store.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.LPush(ctx, key, time.Now().Format(time.TimeOnly)+" "+reason)
+		pipe.LTrim(ctx, key, 0, maxSkips-1)
+		pipe.Expire(ctx, key, sessionTTL)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("unable to record skip in session %s: %w", date, err)
+	}
+	return nil
+}
+
+// Skips returns the latest reasons why trades were skipped in the given trading date.
+func (store *Store) Skips(ctx context.Context, date string, count int64) ([]string, error) {
+	skips, err := store.client.LRange(ctx, keyPrefix+date+":skips", 0, count-1).Result()
+	if err != nil {
+		return nil, fmt.Errorf("unable to get the skips of session %s: %w", date, err)
+	}
+	return skips, nil
+}
+
+// SetLastTrade records the time of the latest trade of the symbol in the given trading date.
+func (store *Store) SetLastTrade(ctx context.Context, date string, symbol string, at time.Time) error {
+	key := keyPrefix + date + ":last_trades"
+	_, err := store.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HSet(ctx, key, symbol, at.Unix())
+		pipe.Expire(ctx, key, sessionTTL)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("unable to record the last trade of %s in session %s: %w", symbol, date, err)
+	}
+	return nil
+}
+
+// LastTrade returns the time of the latest trade of the symbol in the given trading date.
+// It returns the zero time if the symbol was not traded.
+func (store *Store) LastTrade(ctx context.Context, date string, symbol string) (time.Time, error) {
+	at, err := store.client.HGet(ctx, keyPrefix+date+":last_trades", symbol).Int64()
+	if err == redis.Nil {
+		return time.Time{}, nil
+	}
+	if err != nil {
+		return time.Time{}, fmt.Errorf("unable to get the last trade of %s in session %s: %w", symbol, date, err)
+	}
+	return time.Unix(at, 0), nil
+}
+
+// LastTrades returns the time of the latest trade of every symbol traded in the given
+// trading date.
+func (store *Store) LastTrades(ctx context.Context, date string) (map[string]time.Time, error) {
+	values, err := store.client.HGetAll(ctx, keyPrefix+date+":last_trades").Result()
+	if err != nil {
+		return nil, fmt.Errorf("unable to get the last trades of session %s: %w", date, err)
+	}
+	last_trades := make(map[string]time.Time, len(values))
+	for symbol, value := range values {
+		at, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid last trade of %s in session %s: %w", symbol, date, err)
+		}
+		last_trades[symbol] = time.Unix(at, 0)
+	}
+	return last_trades, nil
+}
+
+// ScaleIns returns how many times the position of the symbol was scaled in the given trading date.
+func (store *Store) ScaleIns(ctx context.Context, date string, symbol string) (int, error) {
+	scale_ins, err := store.client.HGet(ctx, keyPrefix+date+":scale_ins", symbol).Int()
+	if err == redis.Nil {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("unable to get the scale-ins of %s in session %s: %w", symbol, date, err)
+	}
+	return scale_ins, nil
+}
+
+// AddScaleIn increments the scale-ins of the symbol in the given trading date.
+func (store *Store) AddScaleIn(ctx context.Context, date string, symbol string) error {
+	key := keyPrefix + date + ":scale_ins"
+	_, err := store.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HIncrBy(ctx, key, symbol, 1)
+		pipe.Expire(ctx, key, sessionTTL)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("unable to add a scale-in of %s to session %s: %w", symbol, date, err)
+	}
+	return nil
+}
+
+// ResetScaleIns resets the scale-ins of the symbol in the given trading date.
+func (store *Store) ResetScaleIns(ctx context.Context, date string, symbol string) error {
+	if err := store.client.HDel(ctx, keyPrefix+date+":scale_ins", symbol).Err(); err != nil {
+		return fmt.Errorf("unable to reset the scale-ins of %s in session %s: %w", symbol, date, err)
+	}
+	return nil
+}
+
+// LockSymbol locks the symbol so only one signal of it is handled at a time, by any
+// instance. It returns the token that releases the lock, or an empty token if the
+// symbol is already locked. The lock expires by itself if it is not released.
+func (store *Store) LockSymbol(ctx context.Context, symbol string) (string, error) {
+	random := make([]byte, 8)
+	if _, err := rand.Read(random); err != nil {
+		return "", fmt.Errorf("unable to generate the lock token: %w", err)
+	}
+	token := hex.EncodeToString(random)
+
+	locked, err := store.client.SetNX(ctx, "lock:symbol:"+symbol, token, lockTTL).Result()
+	if err != nil {
+		return "", fmt.Errorf("unable to lock %s: %w", symbol, err)
+	}
+	if !locked {
+		return "", nil
+	}
+	return token, nil
+}
+
+// UnlockSymbol releases the lock of the symbol, if it is still held with the token.
+// A lock that expired and was taken by another handler is left alone.
+func (store *Store) UnlockSymbol(ctx context.Context, symbol string, token string) error {
+	if err := unlockScript.Run(ctx, store.client, []string{"lock:symbol:" + symbol}, token).Err(); err != nil {
+		return fmt.Errorf("unable to unlock %s: %w", symbol, err)
+	}
+	return nil
+}
+
+// SetSoftStop saves the soft stop of a symbol, replacing the previous one. The soft
+// stops are not tied to a trading date, they are kept while the position is held.
+func (store *Store) SetSoftStop(ctx context.Context, stop models.SoftStop) error {
+	data, err := json.Marshal(stop)
+	if err != nil {
+		return fmt.Errorf("unable to marshal the soft stop of %s: %w", stop.Symbol, err)
+	}
+	if err := store.client.HSet(ctx, softStopsKey, stop.Symbol, data).Err(); err != nil {
+		return fmt.Errorf("unable to save the soft stop of %s: %w", stop.Symbol, err)
+	}
+	return nil
+}
+
+// SoftStops returns the soft stops of every symbol.
+func (store *Store) SoftStops(ctx context.Context) ([]models.SoftStop, error) {
+	values, err := store.client.HGetAll(ctx, softStopsKey).Result()
+	if err != nil {
+		return nil, fmt.Errorf("unable to get the soft stops: %w", err)
+	}
+
+	stops := make([]models.SoftStop, 0, len(values))
+	for _, value := range values {
+		var stop models.SoftStop
+		if err := json.Unmarshal([]byte(value), &stop); err != nil {
+			return nil, fmt.Errorf("unable to parse a soft stop: %w", err)
+		}
+		stops = append(stops, stop)
+	}
+	return stops, nil
+}
+
+// DeleteSoftStop deletes the soft stop of a symbol.
+func (store *Store) DeleteSoftStop(ctx context.Context, symbol string) error {
+	if err := store.client.HDel(ctx, softStopsKey, symbol).Err(); err != nil {
+		return fmt.Errorf("unable to delete the soft stop of %s: %w", symbol, err)
+	}
+	return nil
+}
+
+// MarkReviewed marks the overnight positions of the given trading date as reviewed.
+// It returns false if they were already reviewed.
+func (store *Store) MarkReviewed(ctx context.Context, date string) (bool, error) {
+	marked, err := store.client.SetNX(ctx, keyPrefix+date+":reviewed", 1, sessionTTL).Result()
+	if err != nil {
+		return false, fmt.Errorf("unable to mark session %s as reviewed: %w", date, err)
+	}
+	return marked, nil
+}
+
+// Halt halts the trading of every instance with the given reason, until it is resumed
+// or it is not set again within haltTTL.
+func (store *Store) Halt(ctx context.Context, reason string) error {
+	if err := store.client.Set(ctx, haltKey, reason, haltTTL).Err(); err != nil {
+		return fmt.Errorf("unable to halt the trading: %w", err)
+	}
+	return nil
+}
+
+// Resume resumes the trading halted by Halt.
+func (store *Store) Resume(ctx context.Context) error {
+	if err := store.client.Del(ctx, haltKey).Err(); err != nil {
+		return fmt.Errorf("unable to resume the trading: %w", err)
+	}
+	return nil
+}
+
+// Halted returns the reason why the trading is halted, or an empty string if it is not.
+func (store *Store) Halted(ctx context.Context) (string, error) {
+	reason, err := store.client.Get(ctx, haltKey).Result()
+	if err == redis.Nil {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("unable to check the trading halt: %w", err)
+	}
+	return reason, nil
+}
+
+// SetPaused pauses or resumes the trading of every instance from the user.
+func (store *Store) SetPaused(ctx context.Context, paused bool) error {
+	var err error
+	if paused {
+		err = store.client.Set(ctx, pausedKey, 1, 0).Err()
+	} else {
+		err = store.client.Del(ctx, pausedKey).Err()
+	}
+	if err != nil {
+		return fmt.Errorf("unable to set the trading pause: %w", err)
+	}
+	return nil
+}
+
+// Paused returns true if the trading was paused by the user.
+func (store *Store) Paused(ctx context.Context) (bool, error) {
+	paused, err := store.client.Exists(ctx, pausedKey).Result()
+	if err != nil {
+		return false, fmt.Errorf("unable to check the trading pause: %w", err)
+	}
+	return paused > 0, nil
+}
+
+// AddNews records a scored headline in the given trading date. Only the latest are kept.
+func (store *Store) AddNews(ctx context.Context, date string, news models.ScoredNews) error {
+	data, err := json.Marshal(news)
+	if err != nil {
+		return fmt.Errorf("unable to marshal the news %d: %w", news.ID, err)
+	}
+	key := keyPrefix + date + ":news"
+	_, err = store.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.LPush(ctx, key, data)
+		pipe.LTrim(ctx, key, 0, maxNews-1)
+		pipe.Expire(ctx, key, sessionTTL)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("unable to record the news in session %s: %w", date, err)
+	}
+	return nil
+}
+
+// News returns the latest scored headlines of the given trading date, the newest first.
+func (store *Store) News(ctx context.Context, date string, count int64) ([]models.ScoredNews, error) {
+	values, err := store.client.LRange(ctx, keyPrefix+date+":news", 0, count-1).Result()
+	if err != nil {
+		return nil, fmt.Errorf("unable to get the news of session %s: %w", date, err)
+	}
+
+	news := make([]models.ScoredNews, 0, len(values))
+	for _, value := range values {
+		var scored models.ScoredNews
+		if err := json.Unmarshal([]byte(value), &scored); err != nil {
+			return nil, fmt.Errorf("unable to parse the news: %w", err)
+		}
+		news = append(news, scored)
+	}
+	return news, nil
+}
@@ -0,0 +1,136 @@
+// Package signals resolves the trading signals of a symbol against its current
+// position, so a stream of headlines about the same ticker does not flip it back
+// and forth.
+package signals
+
+import (
+	"fmt"
+	"time"
+)
+
+const (
+	// SameIgnore ignores the signals in the direction of the open position.
+	SameIgnore = "ignore"
+	// SameScale adds to the open position, up to the max scale-ins.
+	SameScale = "scale"
+
+	// OppositeFlatten closes the open position on an opposite signal.
+	OppositeFlatten = "flatten"
+	// OppositeReverse closes the open position and opens one in the other direction.
+	OppositeReverse = "reverse"
+)
+
+// Direction is the direction of the position of a symbol.
+type Direction int
+
+const (
+	Flat Direction = iota
+	Long
+	Short
+)
+
+// String returns the name of the direction.
+func (d Direction) String() string {
+	switch d {
+	case Long:
+		return "long"
+	case Short:
+		return "short"
+	default:
+		return "flat"
+	}
+}
+
+// Action is what to do with a signal.
+type Action int
+
+const (
+	// Ignore does not trade.
+	Ignore Action = iota
+	// Open opens a position in the direction of the signal.
+	Open
+	// ScaleIn adds to the open position.
+	ScaleIn
+	// Flatten closes the open position.
+	Flatten
+	// Reverse closes the open position and opens one in the direction of the signal.
+	Reverse
+)
+
+// String returns the name of the action.
+func (a Action) String() string {
+	return [...]string{"ignore", "open", "scale_in", "flatten", "reverse"}[a]
+}
+
+// State is the state of a symbol when a signal arrives.
+type State struct {
+	Direction Direction
+	LastTrade time.Time
+	ScaleIns  int
+}
+
+// Policy decides how the signals of a symbol are resolved.
+type Policy struct {
+	Cooldown      time.Duration
+	SameDirection string
+	MaxScaleIns   int
+	Opposite      string
+}
+
+// Resolve returns the action for a buy (buy is true) or sell signal given the state of
+// the symbol, and the reason when the signal is ignored.
+//
+//	flat                 -> open
+//	in cooldown          -> ignore
+//	same direction       -> ignore, or scale in while under the max scale-ins
+//	opposite direction   -> flatten or reverse
+func (p Policy) Resolve(state State, buy bool, now time.Time) (Action, string) {
+	if !state.LastTrade.IsZero() && now.Sub(state.LastTrade) < p.Cooldown {
+		return Ignore, fmt.Sprintf("in cooldown until %s", state.LastTrade.Add(p.Cooldown).Format(time.TimeOnly))
+	}
+
+	wanted := Short
+	if buy {
+		wanted = Long
+	}
+
+	switch state.Direction {
+	case Flat:
+		return Open, ""
+	case wanted:
+		if p.SameDirection != SameScale {
+			return Ignore, fmt.Sprintf("already %s", state.Direction)
+		}
+		if p.MaxScaleIns > 0 && state.ScaleIns >= p.MaxScaleIns {
+			return Ignore, fmt.Sprintf("already scaled in %d times", state.ScaleIns)
+		}
+		return ScaleIn, ""
+	default:
+		if p.Opposite == OppositeReverse {
+			return Reverse, ""
+		}
+		return Flatten, ""
+	}
+}
+
+// Validate returns an error if the policy is invalid, and fills the defaults.
+func (p *Policy) Validate() error {
+	switch p.SameDirection {
+	case "":
+		p.SameDirection = SameIgnore
+	case SameIgnore, SameScale:
+	default:
+		return fmt.Errorf("unknown same direction policy %q", p.SameDirection)
+	}
+	switch p.Opposite {
+	case "":
+		p.Opposite = OppositeFlatten
+	case OppositeFlatten, OppositeReverse:
+	default:
+		return fmt.Errorf("unknown opposite policy %q", p.Opposite)
+	}
+	if p.Cooldown < 0 || p.MaxScaleIns < 0 {
+		return fmt.Errorf("cooldown and max scale-ins can't be negative")
+	}
+	return nil
+}
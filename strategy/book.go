@@ -0,0 +1,177 @@
+// Package strategy defines the trading strategies, the live one and the
+// shadow variants that are only evaluated on paper.
+package strategy
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	bookPrefix = "shadow:"
+	bookTTL    = 7 * 24 * time.Hour
+)
+
+// Fill is a hypothetical fill of a strategy, priced with the quote at decision time.
+type Fill struct {
+	Symbol   string    `json:"symbol"`
+	Side     Decision  `json:"side"`
+	Qty      float64   `json:"qty"`
+	Price    float64   `json:"price"`
+	Score    int       `json:"score"`
+	Headline string    `json:"headline"`
+	Time     time.Time `json:"time"`
+}
+
+// Result is the performance of a strategy in a trading day.
+type Result struct {
+	Name       string
+	Trades     int
+	Realized   float64
+	Unrealized float64
+}
+
+// Total returns the realized plus the unrealized P&L.
+func (r Result) Total() float64 {
+	return r.Realized + r.Unrealized
+}
+
+// Book records the hypothetical fills of every strategy in redis.
+type Book struct {
+	client *redis.Client
+}
+
+// NewBook returns a new Book that uses the given redis client.
+func NewBook(client *redis.Client) *Book {
+	return &Book{
+		client: client,
+	}
+}
+
+// Record saves the fill of the strategy in the given trading date.
+// It returns an error if redis could not be reached.
+func (book *Book) Record(ctx context.Context, date string, name string, fill Fill) error {
+	data, err := json.Marshal(fill)
+	if err != nil {
+		return fmt.Errorf("unable to marshal fill: %w", err)
+	}
+
+	index_key := bookPrefix + date
+	fills_key := index_key + ":" + name
+	_, err = book.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.SAdd(ctx, index_key, name)
+		pipe.RPush(ctx, fills_key, data)
+		pipe.Expire(ctx, index_key, bookTTL)
+		pipe.Expire(ctx, fills_key, bookTTL)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("unable to record fill of %s: %w", name, err)
+	}
+	return nil
+}
+
+// Report returns the results of every strategy in the given trading date, sorted by
+// total P&L. The open positions are marked to market with the price function.
+func (book *Book) Report(ctx context.Context, date string, price func(symbol string) (float64, error)) ([]Result, error) {
+	index_key := bookPrefix + date
+	names, err := book.client.SMembers(ctx, index_key).Result()
+	if err != nil {
+		return nil, fmt.Errorf("unable to list strategies: %w", err)
+	}
+
+	results := make([]Result, 0, len(names))
+	for _, name := range names {
+		raw_fills, err := book.client.LRange(ctx, index_key+":"+name, 0, -1).Result()
+		if err != nil {
+			return nil, fmt.Errorf("unable to get fills of %s: %w", name, err)
+		}
+
+		fills := make([]Fill, 0, len(raw_fills))
+		for _, raw := range raw_fills {
+			var fill Fill
+			if err := json.Unmarshal([]byte(raw), &fill); err != nil {
+				return nil, fmt.Errorf("unable to read fill of %s: %w", name, err)
+			}
+			fills = append(fills, fill)
+		}
+
+		results = append(results, evaluate(name, fills, price))
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		return results[i].Total() > results[j].Total()
+	})
+	return results, nil
+}
+
+// FormatReport returns the results as a table.
+func FormatReport(results []Result) string {
+	var builder strings.Builder
+	fmt.Fprintf(&builder, "%-20s %8s %12s %12s %12s\n", "strategy", "trades", "realized", "unrealized", "total")
+	for _, result := range results {
+		fmt.Fprintf(&builder, "%-20s %8d %12.2f %12.2f %12.2f\n",
+			result.Name, result.Trades, result.Realized, result.Unrealized, result.Total())
+	}
+	return builder.String()
+}
+
+// position is the hypothetical position of a strategy in a symbol,
+// a negative quantity is a short.
+type position struct {
+	qty   float64
+	price float64
+}
+
+// evaluate replays the fills of a strategy and returns its result. If the price of
+// an open position can't be found it is marked at its entry price.
+func evaluate(name string, fills []Fill, price func(symbol string) (float64, error)) Result {
+	result := Result{Name: name, Trades: len(fills)}
+	positions := make(map[string]*position)
+
+	for _, fill := range fills {
+		pos, exists := positions[fill.Symbol]
+		if !exists {
+			pos = &position{}
+			positions[fill.Symbol] = pos
+		}
+
+		qty := fill.Qty
+		if fill.Side == Sell {
+			qty = -qty
+		}
+
+		// The part of the fill that reduces the position realizes P&L.
+		if pos.qty*qty < 0 {
+			closed := math.Min(math.Abs(qty), math.Abs(pos.qty))
+			direction := math.Copysign(1, pos.qty)
+			result.Realized += closed * (fill.Price - pos.price) * direction
+			pos.qty += math.Copysign(closed, qty)
+			qty -= math.Copysign(closed, qty)
+		}
+
+		if qty != 0 {
+			pos.price = (pos.qty*pos.price + qty*fill.Price) / (pos.qty + qty)
+			pos.qty += qty
+		}
+	}
+
+	for symbol, pos := range positions {
+		if pos.qty == 0 {
+			continue
+		}
+		current, err := price(symbol)
+		if err != nil {
+			current = pos.price
+		}
+		result.Unrealized += pos.qty * (current - pos.price)
+	}
+	return result
+}
@@ -0,0 +1,101 @@
+// Package strategy defines the trading strategies, the live one and the
+// shadow variants that are only evaluated on paper.
+package strategy
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/jmvdr-iscte/TradingBotCli/open_ai"
+	"github.com/jmvdr-iscte/TradingBotCli/risk"
+)
+
+// LiveName is the name of the strategy that places real orders.
+const LiveName = "live"
+
+// Decision is the action a strategy takes given a sentiment score.
+type Decision int
+
+const (
+	Hold Decision = iota
+	Buy
+	Sell
+)
+
+// String returns the string value of the decision.
+func (d Decision) String() string {
+	return [...]string{"hold", "buy", "sell"}[d]
+}
+
+// Strategy is a prompt, the thresholds that turn the score into a decision
+// and the risk profile used to size the orders.
+type Strategy struct {
+	Name      string       `json:"name"`
+	Prompt    string       `json:"prompt"`
+	HighLimit int          `json:"high_limit"`
+	LowLimit  int          `json:"low_limit"`
+	RiskName  string       `json:"risk"`
+	Profile   risk.Profile `json:"-"`
+}
+
+// Live returns the strategy that places real orders with the given risk profile.
+func Live(profile risk.Profile) Strategy {
+	return Strategy{
+		Name:      LiveName,
+		Prompt:    open_ai.Prompt,
+		HighLimit: profile.HighLimit,
+		LowLimit:  profile.LowLimit,
+		RiskName:  profile.Name,
+		Profile:   profile,
+	}
+}
+
+// Decide returns the decision of the strategy for the given sentiment score.
+func (s Strategy) Decide(score int) Decision {
+	if score >= s.HighLimit {
+		return Buy
+	} else if score <= s.LowLimit && score > 0 {
+		return Sell
+	}
+	return Hold
+}
+
+// LoadFile returns the strategies declared in the given json file. The missing
+// prompts and limits are filled with the ones of the live strategy for the same risk.
+// It returns an error if the file can't be read or a strategy is invalid.
+func LoadFile(path string, profiles risk.Profiles) ([]Strategy, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read strategies file: %w", err)
+	}
+
+	var strategies []Strategy
+	if err := json.Unmarshal(data, &strategies); err != nil {
+		return nil, fmt.Errorf("unable to parse strategies file: %w", err)
+	}
+
+	for i := range strategies {
+		strategy := &strategies[i]
+		if strategy.Name == "" || strategy.Name == LiveName {
+			return nil, fmt.Errorf("invalid strategy name %q", strategy.Name)
+		}
+
+		strategy.Profile, err = profiles.Get(strategy.RiskName)
+		if err != nil {
+			return nil, fmt.Errorf("strategy %s: %w", strategy.Name, err)
+		}
+
+		live := Live(strategy.Profile)
+		if strategy.Prompt == "" {
+			strategy.Prompt = live.Prompt
+		}
+		if strategy.HighLimit == 0 {
+			strategy.HighLimit = live.HighLimit
+		}
+		if strategy.LowLimit == 0 {
+			strategy.LowLimit = live.LowLimit
+		}
+	}
+	return strategies, nil
+}
@@ -4,18 +4,38 @@ package utils
 import (
 	"math"
 
-	"github.com/jmvdr-iscte/TradingBotCli/enums"
+	"github.com/jmvdr-iscte/TradingBotCli/risk"
 )
 
-// CalculateQuantity returns the quantity of the side, given the buying power, latest quote
-// and risk. This is recomended to be used only if you have less than 25,000 in equity.
-func CalculateQuantity(buying_power float64, latest_quote float64, risk enums.Risk) int64 {
-	switch risk {
-	case enums.Safe:
-		return int64(math.Abs((math.Min(buying_power*0.1/latest_quote, 20))))
-	case enums.Power:
-		return int64(math.Abs((math.Max(buying_power*0.1/latest_quote, 20))))
-	default:
+// BuyQuantity returns the quantity of the buy, given the risk profile, sentiment
+// response, buying power and the latest quote of the stock.
+func BuyQuantity(profile risk.Profile, response int, buying_power float64, latest_quote float64) float64 {
+	band, ok := profile.BuyBand(response)
+	if !ok {
 		return 0
 	}
+	return bandQuantity(band, profile.Multiplier, buying_power, latest_quote)
+}
+
+// SellQuantity returns the quantity of the sell, given the risk profile, sentiment
+// response, buying power and the latest quote of the stock.
+func SellQuantity(profile risk.Profile, response int, buying_power float64, latest_quote float64) float64 {
+	band, ok := profile.SellBand(response)
+	if !ok {
+		return 0
+	}
+	return bandQuantity(band, profile.Multiplier, buying_power, latest_quote)
+}
+
+// bandQuantity returns the percentage of the buying power in shares, which can be fractional, limited
+// by the cap and the floor of the band and scaled by the multiplier.
+func bandQuantity(band risk.Band, multiplier float64, buying_power float64, latest_quote float64) float64 {
+	quantity := buying_power * band.Percent / latest_quote
+	if band.Cap > 0 {
+		quantity = math.Min(quantity, band.Cap)
+	}
+	if band.Floor > 0 {
+		quantity = math.Max(quantity, band.Floor)
+	}
+	return math.Abs(quantity * multiplier)
 }
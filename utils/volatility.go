@@ -0,0 +1,63 @@
+// Package utils encapsulates all the utilities.
+package utils
+
+import (
+	"math"
+)
+
+// ATR returns the average true range of the last period bars, given the high, low
+// and close prices of the bars in chronological order. It returns 0 if there are
+// not enough bars.
+func ATR(highs []float64, lows []float64, closes []float64, period int) float64 {
+	if period <= 0 || len(closes) < period+1 || len(highs) != len(closes) || len(lows) != len(closes) {
+		return 0
+	}
+
+	sum := 0.0
+	for i := len(closes) - period; i < len(closes); i++ {
+		true_range := math.Max(highs[i]-lows[i], math.Max(math.Abs(highs[i]-closes[i-1]), math.Abs(lows[i]-closes[i-1])))
+		sum += true_range
+	}
+	return sum / float64(period)
+}
+
+// RealizedVolatility returns the standard deviation of the log returns of the last
+// period bars, given the close prices in chronological order. It returns 0 if there
+// are not enough bars.
+func RealizedVolatility(closes []float64, period int) float64 {
+	if period < 2 || len(closes) < period+1 {
+		return 0
+	}
+
+	returns := make([]float64, 0, period)
+	for i := len(closes) - period; i < len(closes); i++ {
+		if closes[i-1] <= 0 || closes[i] <= 0 {
+			return 0
+		}
+		returns = append(returns, math.Log(closes[i]/closes[i-1]))
+	}
+
+	mean := 0.0
+	for _, r := range returns {
+		mean += r
+	}
+	mean /= float64(len(returns))
+
+	variance := 0.0
+	for _, r := range returns {
+		variance += (r - mean) * (r - mean)
+	}
+	return math.Sqrt(variance / float64(len(returns)-1))
+}
+
+// VolatilityQuantity returns the quantity that loses risk_per_trade of the equity
+// if the stop, stop_distance dollars away from the entry, is hit. The quantity is
+// limited to what the buying power can pay at the latest quote.
+func VolatilityQuantity(equity float64, risk_per_trade float64, stop_distance float64, buying_power float64, latest_quote float64) float64 {
+	if stop_distance <= 0 || latest_quote <= 0 {
+		return 0
+	}
+	quantity := equity * risk_per_trade / stop_distance
+	quantity = math.Min(quantity, buying_power/latest_quote)
+	return math.Abs(quantity)
+}
@@ -5,6 +5,7 @@ import (
 	"context"
 
 	"github.com/hibiken/asynq"
+	"github.com/jmvdr-iscte/TradingBotCli/approval"
 	"github.com/jmvdr-iscte/TradingBotCli/models"
 )
 
@@ -15,6 +16,12 @@ type TaskDistributor interface {
 		order *models.Message,
 		opts ...asynq.Option,
 	) error
+	DistributeTaskApprovedOrder(
+		ctx context.Context,
+		decision *approval.Decision,
+		opts ...asynq.Option,
+	) error
+	Close() error
 }
 
 // RedisTaskDistributor is the asynq client.
@@ -29,3 +36,8 @@ func NewRedisTaskDistributor(redisOpt asynq.RedisClientOpt) TaskDistributor {
 		client: client,
 	}
 }
+
+// Close closes the connection with redis.
+func (distributor *RedisTaskDistributor) Close() error {
+	return distributor.client.Close()
+}
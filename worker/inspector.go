@@ -0,0 +1,77 @@
+// Package worker encapsules all the asynq modules.
+package worker
+
+import (
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/hibiken/asynq"
+)
+
+var queues = []string{QueueCritical, QueueDefault}
+
+// CancelPendingTasks deletes every pending and scheduled task of the bot queues.
+// It returns the amount of deleted tasks and an error if redis could not be reached.
+func CancelPendingTasks(redisOpt asynq.RedisClientOpt) (int, error) {
+	inspector := asynq.NewInspector(redisOpt)
+	defer inspector.Close()
+
+	existing, err := inspector.Queues()
+	if err != nil {
+		return 0, fmt.Errorf("unable to list queues: %w", err)
+	}
+
+	deleted := 0
+	for _, queue := range existing {
+		if !slices.Contains(queues, queue) {
+			continue
+		}
+		pending, err := inspector.DeleteAllPendingTasks(queue)
+		if err != nil {
+			return deleted, fmt.Errorf("unable to delete pending tasks: %w", err)
+		}
+		scheduled, err := inspector.DeleteAllScheduledTasks(queue)
+		if err != nil {
+			return deleted, fmt.Errorf("unable to delete scheduled tasks: %w", err)
+		}
+		deleted += pending + scheduled
+	}
+	return deleted, nil
+}
+
+// DrainQueues waits until every task of the bot queues has been processed.
+// It returns an error if the queues are still not empty after the timeout.
+func DrainQueues(redisOpt asynq.RedisClientOpt, timeout time.Duration) error {
+	inspector := asynq.NewInspector(redisOpt)
+	defer inspector.Close()
+
+	deadline := time.Now().Add(timeout)
+	for {
+		existing, err := inspector.Queues()
+		if err != nil {
+			return fmt.Errorf("unable to list queues: %w", err)
+		}
+
+		remaining := 0
+		for _, queue := range existing {
+			if !slices.Contains(queues, queue) {
+				continue
+			}
+			info, err := inspector.GetQueueInfo(queue)
+			if err != nil {
+				return fmt.Errorf("unable to get queue info: %w", err)
+			}
+			remaining += info.Pending + info.Scheduled + info.Active
+		}
+
+		if remaining == 0 {
+			return nil
+		}
+
+		if time.Now().After(deadline) {
+			return fmt.Errorf("%d tasks still pending after %s", remaining, timeout)
+		}
+		time.Sleep(500 * time.Millisecond)
+	}
+}
@@ -3,13 +3,33 @@ package worker
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
+	"time"
 
 	"github.com/hibiken/asynq"
+	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
 
+	alpacaapi "github.com/alpacahq/alpaca-trade-api-go/v3/alpaca"
 	"github.com/jmvdr-iscte/TradingBotCli/alpaca"
+	"github.com/jmvdr-iscte/TradingBotCli/approval"
+	"github.com/jmvdr-iscte/TradingBotCli/cash"
+	"github.com/jmvdr-iscte/TradingBotCli/compliance"
+	"github.com/jmvdr-iscte/TradingBotCli/leader"
+	"github.com/jmvdr-iscte/TradingBotCli/logger"
+	"github.com/jmvdr-iscte/TradingBotCli/models"
+	"github.com/jmvdr-iscte/TradingBotCli/notify"
 	"github.com/jmvdr-iscte/TradingBotCli/open_ai"
+	"github.com/jmvdr-iscte/TradingBotCli/pdt"
+	"github.com/jmvdr-iscte/TradingBotCli/position"
+	"github.com/jmvdr-iscte/TradingBotCli/risk"
+	"github.com/jmvdr-iscte/TradingBotCli/sentiment"
+	"github.com/jmvdr-iscte/TradingBotCli/session"
+	"github.com/jmvdr-iscte/TradingBotCli/signals"
+	"github.com/jmvdr-iscte/TradingBotCli/strategy"
 	"github.com/sashabaranov/go-openai"
+	"github.com/shopspring/decimal"
 )
 
 const (
@@ -20,6 +40,7 @@ const (
 // TaskProcessor interface, has all the function that a processor should implement.
 type TaskProcessor interface {
 	Start() error
+	Shutdown()
 	ProcessTaskProcessOrder(ctx context.Context, task *asynq.Task) error
 }
 
@@ -29,12 +50,43 @@ type RedisTaskProcessor struct {
 	server        *asynq.Server
 	alpaca_client *alpaca.AlpacaClient
 	openai_client *openai.Client
+	session       *session.Store
+	lease         *leader.Lease
+	book          *strategy.Book
+	shadows       []strategy.Strategy
+	profiles      risk.Profiles
+	signals       signals.Policy
+	window        *sentiment.Window
+	positions     *position.Manager
+	ledger        *pdt.Ledger
+	notifier      *notify.Notifier
+	approvals     *approval.Store
+}
+
+// ProcessorConfig has the dependencies and settings of the task processor.
+type ProcessorConfig struct {
+	ShutdownTimeout time.Duration
+	Session         *session.Store
+	Lease           *leader.Lease
+	DryRun          bool
+	Book            *strategy.Book
+	Shadows         []strategy.Strategy
+	Profiles        risk.Profiles
+	Compliance      *compliance.Engine
+	Signals         signals.Policy
+	Window          *sentiment.Window
+	Positions       *position.Manager
+	Ledger          *pdt.Ledger
+	Cash            *cash.Ledger
+	Notifier        *notify.Notifier
+	Approvals       *approval.Store
 }
 
 // New RedisTaskProcessor returns an instance of a new task
 // processor.
 func NewRedisTaskProcessor(
 	redisOpt asynq.RedisClientOpt,
+	cfg ProcessorConfig,
 ) TaskProcessor {
 	//Add list priorities
 	server := asynq.NewServer(
@@ -44,24 +96,132 @@ func NewRedisTaskProcessor(
 				QueueCritical: 10,
 				QueueDefault:  6,
 			},
+			ShutdownTimeout: cfg.ShutdownTimeout,
+			// The logs of asynq go through the global logger, the dashboard redirects them too.
+			Logger:   logger.Asynq{},
+			LogLevel: logger.AsynqLevel(),
 			ErrorHandler: asynq.ErrorHandlerFunc(func(ctx context.Context, task *asynq.Task, err error) {
-				log.Error().Err(err).Str("type", task.Type()).
-					Bytes("payload", task.Payload()).Msg("process task failed")
+				// Only the ids of the payload are logged, the headline and the rest stay out of the logs.
+				task_log := taskLogger(ctx, task)
+				task_log.Error().Err(err).Str("type", task.Type()).Msg("process task failed")
+				cfg.Notifier.Notify(notify.Error, "", fmt.Sprintf("process task failed: %s", err))
 			}),
 		},
 	)
 	alpaca_client := alpaca.LoadClient()
+	alpaca_client.SetDryRun(cfg.DryRun)
+	if cfg.Cash != nil {
+		alpaca_client.SetCashAccount(cfg.Cash)
+	}
 	openai_client := open_ai.GetClient()
+	RecordTrades(alpaca_client, cfg.Session, cfg.Ledger, cfg.Notifier)
+	alpaca_client.OnSoftStop(func(stop models.SoftStop) {
+		if err := cfg.Session.SetSoftStop(context.Background(), stop); err != nil {
+			log.Error().Err(err).Str(logger.Symbol, stop.Symbol).Msg("failed to save the soft stop")
+		}
+	})
+	alpaca_client.SetCompliance(cfg.Compliance, func(symbol string) (time.Time, error) {
+		return cfg.Session.LastTrade(context.Background(), session.TradingDate(time.Now()), symbol)
+	})
 	return &RedisTaskProcessor{
 		server:        server,
 		alpaca_client: alpaca_client,
 		openai_client: openai_client,
+		session:       cfg.Session,
+		lease:         cfg.Lease,
+		book:          cfg.Book,
+		shadows:       cfg.Shadows,
+		profiles:      cfg.Profiles,
+		signals:       cfg.Signals,
+		window:        cfg.Window,
+		positions:     cfg.Positions,
+		ledger:        cfg.Ledger,
+		notifier:      cfg.Notifier,
+		approvals:     cfg.Approvals,
 	}
 }
 
+// RecordTrades registers on the client the function that records every trade in the
+// session and, when the day trade ledger is enabled, the openings and the day trades.
+// Every fill is notified.
+func RecordTrades(client *alpaca.AlpacaClient, store *session.Store, ledger *pdt.Ledger, notifier *notify.Notifier) {
+	client.OnTrade(func(symbol string, qty decimal.Decimal, side alpacaapi.Side, opening bool) {
+		action := "closed"
+		if opening {
+			action = "opened"
+		}
+		notifier.Notify(notify.Fill, symbol, fmt.Sprintf("%s %s, position %s", side, qty, action))
+
+		trade_log := log.With().Str(logger.Symbol, symbol).Logger()
+		ctx := context.Background()
+		now := time.Now()
+		date := session.TradingDate(now)
+		if err := store.AddTrade(ctx, date); err != nil {
+			trade_log.Error().Err(err).Msg("failed to record trade")
+		}
+		if err := store.SetLastTrade(ctx, date, symbol, now); err != nil {
+			trade_log.Error().Err(err).Msg("failed to record the last trade")
+		}
+
+		if ledger == nil {
+			return
+		}
+		if opening {
+			if err := ledger.Open(ctx, date, symbol); err != nil {
+				trade_log.Error().Err(err).Msg("failed to record the opening")
+			}
+			return
+		}
+		day_trade, err := ledger.Close(ctx, date, symbol, now)
+		if err != nil {
+			trade_log.Error().Err(err).Msg("failed to record the closing")
+		}
+		if day_trade {
+			trade_log.Warn().Msg("day trade recorded")
+		}
+	})
+}
+
+// taskContext returns the context with a logger of the task, with its id, the id of
+// the news and the symbol it trades.
+func taskContext(ctx context.Context, news_id int64, symbols []string) context.Context {
+	task_id, _ := asynq.GetTaskID(ctx)
+	fields := log.With().Str(logger.TaskID, task_id).Int64(logger.NewsID, news_id)
+	if len(symbols) > 0 {
+		fields = fields.Str(logger.Symbol, symbols[0])
+	}
+	return fields.Logger().WithContext(ctx)
+}
+
+// taskLogger returns the logger of a failed task. The payload is only read for its ids,
+// it is never logged as a whole.
+func taskLogger(ctx context.Context, task *asynq.Task) *zerolog.Logger {
+	var news_id int64
+	var symbols []string
+	switch task.Type() {
+	case TaskProcessOrder:
+		var payload models.Message
+		if err := json.Unmarshal(task.Payload(), &payload); err == nil {
+			news_id, symbols = payload.ID, payload.Symbols
+		}
+	case TaskApprovedOrder:
+		var decision approval.Decision
+		if err := json.Unmarshal(task.Payload(), &decision); err == nil {
+			news_id, symbols = decision.Message.ID, []string{decision.Symbol}
+		}
+	}
+	return log.Ctx(taskContext(ctx, news_id, symbols))
+}
+
 // Start initializes the asynq server.
 func (processor *RedisTaskProcessor) Start() error {
 	mux := asynq.NewServeMux() //register each task
 	mux.HandleFunc(TaskProcessOrder, processor.ProcessTaskProcessOrder)
+	mux.HandleFunc(TaskApprovedOrder, processor.ProcessTaskApprovedOrder)
 	return processor.server.Start(mux)
 }
+
+// Shutdown stops fetching new tasks and waits for the active ones to finish.
+func (processor *RedisTaskProcessor) Shutdown() {
+	processor.server.Shutdown()
+}
@@ -0,0 +1,131 @@
+// Package worker encapsules all the asynq modules.
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	alpacaapi "github.com/alpacahq/alpaca-trade-api-go/v3/alpaca"
+	"github.com/hibiken/asynq"
+	"github.com/jmvdr-iscte/TradingBotCli/alpaca"
+	"github.com/jmvdr-iscte/TradingBotCli/approval"
+	"github.com/jmvdr-iscte/TradingBotCli/logger"
+	"github.com/jmvdr-iscte/TradingBotCli/models"
+	"github.com/jmvdr-iscte/TradingBotCli/notify"
+	"github.com/jmvdr-iscte/TradingBotCli/risk"
+	"github.com/rs/zerolog/log"
+)
+
+const TaskApprovedOrder = "task:approved_order"
+
+// DistributeTaskApprovedOrder returns an error if anything goes wrong with
+// distributing the approved decision to a redis queue.
+func (distributor *RedisTaskDistributor) DistributeTaskApprovedOrder(
+	ctx context.Context,
+	decision *approval.Decision,
+	opts ...asynq.Option,
+) error {
+	json_payload, err := json.Marshal(decision)
+	if err != nil {
+		return fmt.Errorf("failed to marshal task payload %w", err)
+	}
+
+	task := asynq.NewTask(TaskApprovedOrder, json_payload, opts...)
+
+	info, err := distributor.client.EnqueueContext(ctx, task)
+	if err != nil {
+		return fmt.Errorf("failed to enqueue task %w", err)
+	}
+	log.Info().Str(logger.TaskID, info.ID).Int64(logger.NewsID, decision.Message.ID).Str(logger.Symbol, decision.Symbol).
+		Str("decision", decision.ID).Str("queue", info.Queue).Msg("task enqueued")
+	return nil
+}
+
+// ProcessTaskApprovedOrder returns an error if it was not able to process the task.
+// The approved decision resumes where it was parked, so the signal is resolved again
+// against the current position and the order is sized again.
+func (processor *RedisTaskProcessor) ProcessTaskApprovedOrder(ctx context.Context, task *asynq.Task) error {
+	var decision approval.Decision
+	if err := json.Unmarshal(task.Payload(), &decision); err != nil {
+		return fmt.Errorf("failed to unmarshal payload: %w", asynq.SkipRetry)
+	}
+	ctx = taskContext(ctx, decision.Message.ID, decision.Message.Symbols)
+	log.Ctx(ctx).Info().Str("decision", decision.ID).Msg("processing approved task")
+
+	profile, err := processor.profiles.Get(decision.Message.Risk)
+	if err != nil {
+		return fmt.Errorf("failed to get the risk profile: %w", asynq.SkipRetry)
+	}
+
+	halted, err := processor.haltReason(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to check the trading halt: %w", err)
+	}
+	if halted != "" {
+		processor.skipped(ctx, &alpaca.SkipError{Symbol: decision.Symbol, Side: alpacaapi.Side(decision.Side), Rule: "halt", Reason: halted})
+		return nil
+	}
+
+	// The decision may wait longer than a restart, so it is also traded when the instance of
+	// this worker is the current leader.
+	valid, err := processor.lease.Valid(ctx, decision.Message.Fence)
+	if err == nil && !valid {
+		valid, err = processor.lease.Valid(ctx, processor.lease.Token())
+	}
+	if err != nil {
+		return fmt.Errorf("failed to check the fencing token: %w", err)
+	}
+	if !valid {
+		log.Ctx(ctx).Warn().Str("decision", decision.ID).Msg("discarding a decision approved under a previous leader")
+		processor.notifier.Notify(notify.Error, decision.Symbol, fmt.Sprintf("approved decision %s was dropped, it was parked under a previous leader",
+			decision.ID))
+		return fmt.Errorf("stale fencing token %d: %w", decision.Message.Fence, asynq.SkipRetry)
+	}
+	return processor.resolve(ctx, decision.Message, decision.Buy, decision.Score, profile, true)
+}
+
+// park parks the trade until it is approved. It returns false if the trade does not need
+// approval, because its value is under the minimum of the approval mode.
+func (processor *RedisTaskProcessor) park(ctx context.Context, payload models.Message, buy bool, response int, profile risk.Profile) (bool, error) {
+	symbol := payload.Symbols[0]
+	side := alpacaapi.Sell
+	if buy {
+		side = alpacaapi.Buy
+	}
+
+	qty, err := processor.alpaca_client.GetQuantity(response, symbol, side, profile)
+	if err != nil {
+		return false, fmt.Errorf("failed to size the decision: %w", err)
+	}
+	// A decision that can't be priced could skip the approval, so it is not traded.
+	price, err := processor.alpaca_client.GetQuote(symbol, side)
+	if err != nil {
+		return false, fmt.Errorf("failed to price the decision: %w", err)
+	}
+	value := qty.InexactFloat64() * price
+	if !processor.approvals.Required(value) {
+		return false, nil
+	}
+
+	decision, err := processor.approvals.Park(ctx, approval.Decision{
+		Symbol:   symbol,
+		Side:     string(side),
+		Qty:      qty.InexactFloat64(),
+		Value:    value,
+		Score:    response,
+		Headline: payload.Headline,
+		Buy:      buy,
+		Message:  payload,
+	})
+	if err != nil {
+		return false, err
+	}
+
+	log.Ctx(ctx).Info().Str("decision", decision.ID).Str("side", string(side)).
+		Float64("value", value).Msg("trade waiting for approval")
+	processor.notifier.Notify(notify.ApprovalPending, symbol, fmt.Sprintf("%s, approve with: approve %s",
+		strings.TrimPrefix(decision.String(), decision.ID+" "), decision.ID))
+	return true, nil
+}
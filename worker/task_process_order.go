@@ -4,19 +4,32 @@ package worker
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"math"
 	"strconv"
 	"strings"
+	"time"
 
+	alpacaapi "github.com/alpacahq/alpaca-trade-api-go/v3/alpaca"
 	"github.com/hibiken/asynq"
-	"github.com/jmvdr-iscte/TradingBotCli/enums"
+	"github.com/jmvdr-iscte/TradingBotCli/alpaca"
+	"github.com/jmvdr-iscte/TradingBotCli/logger"
 	"github.com/jmvdr-iscte/TradingBotCli/models"
-	"github.com/jmvdr-iscte/TradingBotCli/open_ai"
+	"github.com/jmvdr-iscte/TradingBotCli/position"
+	"github.com/jmvdr-iscte/TradingBotCli/risk"
+	"github.com/jmvdr-iscte/TradingBotCli/session"
+	"github.com/jmvdr-iscte/TradingBotCli/signals"
+	"github.com/jmvdr-iscte/TradingBotCli/strategy"
 	"github.com/rs/zerolog/log"
 	"github.com/sashabaranov/go-openai"
 )
 
-const TaskProcessOrder = "task:process_order"
+const (
+	TaskProcessOrder = "task:process_order"
+	// reverseTimeout is how long a reversal waits for the close to fill.
+	reverseTimeout = 30 * time.Second
+)
 
 // DistributeTaskProcessOrder returns an error if anything goes wrong with
 // distributing the tasks to a redis queue. If it was able to distribute it
@@ -37,7 +50,11 @@ func (distributor *RedisTaskDistributor) DistributeTaskProcessOrder(
 	if err != nil {
 		return fmt.Errorf("failed to enqueue task %w", err)
 	}
-	log.Info().Msgf("The details are %v ", info)
+	event := log.Info().Str(logger.TaskID, info.ID).Int64(logger.NewsID, order.ID).Str("queue", info.Queue)
+	if len(order.Symbols) > 0 {
+		event = event.Str(logger.Symbol, order.Symbols[0])
+	}
+	event.Msg("task enqueued")
 	return nil
 }
 
@@ -45,53 +62,460 @@ func (distributor *RedisTaskDistributor) DistributeTaskProcessOrder(
 // It is responsible for the sentiment analysis and caling the alpaca sdk in order to
 // sell or buy.
 func (processor *RedisTaskProcessor) ProcessTaskProcessOrder(ctx context.Context, task *asynq.Task) error {
-	riskLevels := map[enums.Risk]bool{
-		enums.Power: true,
-		enums.Safe:  true,
-	}
-
-	var high_limit = 75
-	var low_limit = 25
 	var payload models.Message
 
 	if err := json.Unmarshal(task.Payload(), &payload); err != nil { // guarda na referencia da memória da variavel
 
 		return fmt.Errorf("failed to unmarshal payload: %w", asynq.SkipRetry)
 	}
-	log.Info().Msgf("Processing task: %v", task.ResultWriter().TaskID())
+	ctx = taskContext(ctx, payload.ID, payload.Symbols)
+	log.Ctx(ctx).Info().Msg("processing task")
+
+	profile, err := processor.profiles.Get(payload.Risk)
+	if err != nil {
+		return fmt.Errorf("failed to get the risk profile: %w", asynq.SkipRetry)
+	}
+
+	// The trading is halted while the account is restricted or paused by the user.
+	halted, err := processor.haltReason(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to check the trading halt: %w", err)
+	}
+	if halted != "" {
+		processor.skipped(ctx, &alpaca.SkipError{Symbol: strings.Join(payload.Symbols, ","), Side: "any", Rule: "halt", Reason: halted})
+		return nil
+	}
 
-	response, err := sentimentAnalysis(processor.openai_client, payload)
+	live := strategy.Live(profile)
+	scores := make(map[string]int)
+	response, err := processor.score(ctx, scores, live.Prompt, payload)
 	if err != nil {
 		return fmt.Errorf("failed asking chat gpt: %w", asynq.SkipRetry)
 	}
+	processor.recordNews(ctx, payload, response)
+
+	// Only the orders issued by the current leader are placed.
+	valid, err := processor.lease.Valid(ctx, payload.Fence)
+	if err != nil {
+		return fmt.Errorf("failed to check the fencing token: %w", err)
+	}
+	if !valid {
+		log.Ctx(ctx).Warn().Int64("fence", payload.Fence).Msg("discarding a task issued by a previous leader")
+		return fmt.Errorf("stale fencing token %d: %w", payload.Fence, asynq.SkipRetry)
+	}
+
+	if len(processor.shadows) > 0 {
+		// The shadows are evaluated after the live order so they never delay it.
+		defer processor.evaluateShadows(ctx, payload, scores, append([]strategy.Strategy{live}, processor.shadows...))
+	}
 
-	if riskLevels[payload.Risk] {
-		high_limit = 95
-		low_limit = 5
+	exited, err := processor.reversalExit(ctx, payload, response)
+	if err != nil {
+		return fmt.Errorf("failed to check the sentiment reversal: %w", err)
+	}
+	if exited {
+		return nil
 	}
 
-	if response >= high_limit {
-		if err := processor.alpaca_client.BuyPosition(response, payload.Symbols[0], payload.Risk); err != nil {
-			return fmt.Errorf("failed to buy: %w", asynq.SkipRetry)
+	if processor.window != nil {
+		aggregate, crossed, err := processor.aggregate(ctx, payload.Symbols[0], response, live)
+		if err != nil {
+			return fmt.Errorf("failed to aggregate the sentiment: %w", err)
 		}
-		fmt.Println("Buy: ", payload)
+		if !crossed {
+			return nil
+		}
+		response = aggregate
+	}
+
+	decision := live.Decide(response)
+	if decision == strategy.Hold {
+		return nil
+	}
+	return processor.resolve(ctx, payload, decision == strategy.Buy, response, profile, false)
+}
+
+// resolve trades the signal of the symbol according to the signal policy and the
+// position the symbol already has. Only one signal of a symbol is resolved at a time.
+// In the approval mode the trades that add exposure are parked, unless already approved.
+func (processor *RedisTaskProcessor) resolve(
+	ctx context.Context,
+	payload models.Message,
+	buy bool,
+	response int,
+	profile risk.Profile,
+	approved bool,
+) error {
+	symbol := payload.Symbols[0]
+	side := alpacaapi.Sell
+	if buy {
+		side = alpacaapi.Buy
+	}
+
+	lock, err := processor.session.LockSymbol(ctx, symbol)
+	if err != nil {
+		return fmt.Errorf("failed to lock the symbol: %w", err)
+	}
+	if lock == "" {
+		processor.skipped(ctx, &alpaca.SkipError{Symbol: symbol, Side: side, Reason: "another signal is being handled"})
 		return nil
+	}
+	defer func() {
+		if err := processor.session.UnlockSymbol(context.Background(), symbol, lock); err != nil {
+			log.Ctx(ctx).Error().Err(err).Msg("failed to unlock the symbol")
+		}
+	}()
 
-	} else if response <= low_limit && response > 0 {
+	state, err := processor.signalState(ctx, symbol)
+	if err != nil {
+		return fmt.Errorf("failed to get the state of the symbol: %w", err)
+	}
+
+	action, reason := processor.signals.Resolve(state, buy, time.Now())
+	log.Ctx(ctx).Info().Str("side", string(side)).Str("position", state.Direction.String()).
+		Str("action", action.String()).Msg("resolved signal")
 
-		if err := processor.alpaca_client.SellPosition(payload.Symbols[0], response, payload.Risk); err != nil {
-			return fmt.Errorf("failed to sell, or short: %w", err)
+	if processor.approvals != nil && !approved && action != signals.Ignore && action != signals.Flatten {
+		parked, err := processor.park(ctx, payload, buy, response, profile)
+		if err != nil || parked {
+			return err
 		}
-		fmt.Println("Sell: ", payload)
+	}
+
+	date := session.TradingDate(time.Now())
+	switch action {
+	case signals.Ignore:
+		processor.skipped(ctx, &alpaca.SkipError{Symbol: symbol, Side: side, Reason: reason})
 		return nil
+
+	case signals.Flatten:
+		if err := processor.alpaca_client.CheckDayTradeClose(ctx, processor.ledger, symbol); processor.skipped(ctx, err) {
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("failed to check the day trades: %w", err)
+		}
+		if err := processor.alpaca_client.ClosePosition(symbol); err != nil {
+			return fmt.Errorf("failed to flatten: %w", err)
+		}
+		processor.closeEntry(ctx, symbol)
+		log.Ctx(ctx).Info().Str("headline", payload.Headline).Msg("position flattened")
+
+	case signals.Reverse:
+		if err := processor.alpaca_client.CheckDayTradeClose(ctx, processor.ledger, symbol); processor.skipped(ctx, err) {
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("failed to check the day trades: %w", err)
+		}
+		if err := processor.alpaca_client.ClosePosition(symbol); err != nil {
+			return fmt.Errorf("failed to flatten before reversing: %w", err)
+		}
+		processor.closeEntry(ctx, symbol)
+		// The new side is only opened once the close filled, so it never nets against it.
+		if err := processor.alpaca_client.WaitFlat(symbol, reverseTimeout); err != nil {
+			return fmt.Errorf("failed to flatten before reversing: %w", err)
+		}
+		placed, err := processor.open(ctx, symbol, buy, response, profile)
+		if err != nil {
+			return err
+		}
+		if placed {
+			processor.openEntry(ctx, payload, buy, response)
+			log.Ctx(ctx).Info().Str("side", string(side)).Str("headline", payload.Headline).Msg("position reversed")
+		}
+
+	case signals.Open, signals.ScaleIn:
+		placed, err := processor.open(ctx, symbol, buy, response, profile)
+		if err != nil || !placed {
+			return err
+		}
+		if action == signals.ScaleIn {
+			if err := processor.session.AddScaleIn(ctx, date, symbol); err != nil {
+				log.Ctx(ctx).Error().Err(err).Msg("failed to record the scale-in")
+			}
+			log.Ctx(ctx).Info().Str("side", string(side)).Str("headline", payload.Headline).Msg("position scaled in")
+			return nil
+		}
+		processor.openEntry(ctx, payload, buy, response)
+		log.Ctx(ctx).Info().Str("side", string(side)).Str("headline", payload.Headline).Msg("position opened")
+	}
+
+	if err := processor.session.ResetScaleIns(ctx, date, symbol); err != nil {
+		log.Ctx(ctx).Error().Err(err).Msg("failed to reset the scale-ins")
 	}
 	return nil
 }
 
+// reversalExit closes the position of the symbol if the score of the headline goes
+// against the news that opened it. It returns true if the position was closed.
+func (processor *RedisTaskProcessor) reversalExit(ctx context.Context, payload models.Message, score int) (bool, error) {
+	if processor.positions == nil || processor.positions.Exits.ReversalScore <= 0 {
+		return false, nil
+	}
+
+	symbol := payload.Symbols[0]
+	entry, err := processor.positions.Get(ctx, symbol)
+	if err != nil || entry == nil || !processor.positions.Exits.Reversed(*entry, score) {
+		return false, err
+	}
+
+	lock, err := processor.session.LockSymbol(ctx, symbol)
+	if err != nil || lock == "" {
+		return false, err
+	}
+	defer func() {
+		if err := processor.session.UnlockSymbol(context.Background(), symbol, lock); err != nil {
+			log.Ctx(ctx).Error().Err(err).Msg("failed to unlock the symbol")
+		}
+	}()
+
+	log.Ctx(ctx).Info().Int("score", score).
+		Int64("entry_news_id", entry.NewsID).Int("entry_score", entry.Score).
+		Msgf("sentiment reversed against the %s position opened by %q", entry.Side, entry.Headline)
+	if err := processor.alpaca_client.CheckDayTradeClose(ctx, processor.ledger, symbol); processor.skipped(ctx, err) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	if err := processor.alpaca_client.ClosePosition(symbol); err != nil {
+		return false, err
+	}
+	processor.closeEntry(ctx, symbol)
+	return true, nil
+}
+
+// recordNews records the scored headline in the session, for the dashboard.
+func (processor *RedisTaskProcessor) recordNews(ctx context.Context, payload models.Message, score int) {
+	err := processor.session.AddNews(ctx, session.TradingDate(time.Now()), models.ScoredNews{
+		ID:       payload.ID,
+		Headline: payload.Headline,
+		Symbols:  payload.Symbols,
+		Score:    score,
+		Time:     time.Now(),
+	})
+	if err != nil {
+		log.Ctx(ctx).Error().Err(err).Msg("failed to record the scored news")
+	}
+}
+
+// haltReason returns why the trading is halted, or an empty string if it is not.
+func (processor *RedisTaskProcessor) haltReason(ctx context.Context) (string, error) {
+	halted, err := processor.session.Halted(ctx)
+	if err != nil || halted != "" {
+		return halted, err
+	}
+	paused, err := processor.session.Paused(ctx)
+	if err != nil || !paused {
+		return "", err
+	}
+	return "paused by the user", nil
+}
+
+// openEntry records the news that opened the position of the symbol.
+func (processor *RedisTaskProcessor) openEntry(ctx context.Context, payload models.Message, buy bool, score int) {
+	if processor.positions == nil {
+		return
+	}
+	side := position.Short
+	if buy {
+		side = position.Long
+	}
+	err := processor.positions.Open(ctx, position.Entry{
+		Symbol:   payload.Symbols[0],
+		Side:     side,
+		NewsID:   payload.ID,
+		Headline: payload.Headline,
+		Score:    score,
+		OpenedAt: time.Now(),
+	})
+	if err != nil {
+		log.Ctx(ctx).Error().Err(err).Msg("failed to record the position entry")
+	}
+}
+
+// closeEntry deletes the entry of the position of the symbol.
+func (processor *RedisTaskProcessor) closeEntry(ctx context.Context, symbol string) {
+	if processor.positions == nil {
+		return
+	}
+	if err := processor.positions.Close(ctx, symbol); err != nil {
+		log.Ctx(ctx).Error().Err(err).Msg("failed to delete the position entry")
+	}
+}
+
+// open opens or adds to a long position for a buy, and a short position for a sell.
+// It returns false if the trade was skipped by a pre-trade check, which is not an error.
+func (processor *RedisTaskProcessor) open(ctx context.Context, symbol string, buy bool, response int, profile risk.Profile) (bool, error) {
+	side := alpacaapi.Sell
+	if buy {
+		side = alpacaapi.Buy
+	}
+	if err := processor.alpaca_client.CheckDayTradeOpen(ctx, processor.ledger, symbol, side); processor.skipped(ctx, err) {
+		return false, nil
+	} else if err != nil {
+		return false, fmt.Errorf("failed to check the day trades: %w", err)
+	}
+
+	if buy {
+		err := processor.alpaca_client.BuyPosition(response, symbol, profile)
+		if processor.skipped(ctx, err) {
+			return false, nil
+		} else if err != nil {
+			return false, fmt.Errorf("failed to buy: %w", asynq.SkipRetry)
+		}
+		return true, nil
+	}
+
+	err := processor.alpaca_client.ShortPosition(response, symbol, profile)
+	if processor.skipped(ctx, err) {
+		return false, nil
+	} else if err != nil {
+		return false, fmt.Errorf("failed to short: %w", err)
+	}
+	return true, nil
+}
+
+// aggregate adds the score to the sentiment window of the symbol. It returns the rounded
+// aggregate of the window, and true if it just crossed a threshold of the strategy.
+func (processor *RedisTaskProcessor) aggregate(ctx context.Context, symbol string, score int, live strategy.Strategy) (int, bool, error) {
+	// A score of 0 is a failed analysis.
+	if score <= 0 {
+		return score, false, nil
+	}
+
+	aggregate, count, err := processor.window.Add(ctx, symbol, score, time.Now())
+	if err != nil {
+		return 0, false, err
+	}
+	rounded := int(math.Round(aggregate))
+
+	decision := strategy.Hold
+	if count >= processor.window.Min {
+		decision = live.Decide(rounded)
+	}
+	crossed, err := processor.window.Cross(ctx, symbol, decision.String())
+	if err != nil {
+		return 0, false, err
+	}
+
+	log.Ctx(ctx).Info().Int("score", score).Float64("aggregate", aggregate).
+		Int("headlines", count).Str("decision", decision.String()).Bool("crossed", crossed).Msg("aggregated sentiment")
+	return rounded, crossed && decision != strategy.Hold, nil
+}
+
+// signalState returns the position, the last trade and the scale-ins of the symbol.
+func (processor *RedisTaskProcessor) signalState(ctx context.Context, symbol string) (signals.State, error) {
+	var state signals.State
+
+	qty, err := processor.alpaca_client.GetPositionQty(symbol)
+	if err != nil {
+		return state, err
+	}
+	switch {
+	case qty.IsPositive():
+		state.Direction = signals.Long
+	case qty.IsNegative():
+		state.Direction = signals.Short
+	}
+
+	date := session.TradingDate(time.Now())
+	state.LastTrade, err = processor.session.LastTrade(ctx, date, symbol)
+	if err != nil {
+		return state, err
+	}
+	state.ScaleIns, err = processor.session.ScaleIns(ctx, date, symbol)
+	if err != nil {
+		return state, err
+	}
+	return state, nil
+}
+
+// skipped returns true if the error is a trade skipped by a pre-trade check,
+// in which case the reason is logged and recorded in the session.
+func (processor *RedisTaskProcessor) skipped(ctx context.Context, err error) bool {
+	var skip *alpaca.SkipError
+	if !errors.As(err, &skip) {
+		return false
+	}
+
+	log.Ctx(ctx).Warn().Str("skipped", skip.Symbol).Str("side", string(skip.Side)).Str("rule", skip.Rule).Msg(skip.Reason)
+	if err := processor.session.AddSkip(ctx, session.TradingDate(time.Now()), skip.Error()); err != nil {
+		log.Ctx(ctx).Error().Err(err).Msg("failed to record the skipped trade")
+	}
+	return true
+}
+
+// score returns the sentiment score of the message for the given prompt. The scores
+// are cached by prompt so strategies sharing a prompt only call openAI once.
+func (processor *RedisTaskProcessor) score(ctx context.Context, scores map[string]int, prompt string, m models.Message) (int, error) {
+	if score, exists := scores[prompt]; exists {
+		return score, nil
+	}
+
+	score, err := sentimentAnalysis(ctx, processor.openai_client, prompt, m)
+	if err != nil {
+		return 0, err
+	}
+	scores[prompt] = score
+	return score, nil
+}
+
+// evaluateShadows records the hypothetical fill of every strategy that decides to trade
+// the message, priced with the current quote. The errors are only logged, so they never
+// affect the live strategy.
+func (processor *RedisTaskProcessor) evaluateShadows(ctx context.Context, m models.Message, scores map[string]int, strategies []strategy.Strategy) {
+	if len(m.Symbols) == 0 {
+		return
+	}
+	symbol := m.Symbols[0]
+	date := session.TradingDate(time.Now())
+
+	for _, strat := range strategies {
+		score, err := processor.score(ctx, scores, strat.Prompt, m)
+		if err != nil {
+			log.Ctx(ctx).Error().Err(err).Str("strategy", strat.Name).Msg("failed to score the shadow strategy")
+			continue
+		}
+
+		decision := strat.Decide(score)
+		if decision == strategy.Hold {
+			continue
+		}
+
+		side := alpacaapi.Buy
+		if decision == strategy.Sell {
+			side = alpacaapi.Sell
+		}
+
+		qty, err := processor.alpaca_client.GetQuantity(score, symbol, side, strat.Profile)
+		if err != nil || !qty.IsPositive() {
+			continue
+		}
+
+		price, err := processor.alpaca_client.GetQuote(symbol, side)
+		if err != nil {
+			log.Ctx(ctx).Error().Err(err).Str("strategy", strat.Name).Msg("failed to price the shadow fill")
+			continue
+		}
+
+		fill := strategy.Fill{
+			Symbol:   symbol,
+			Side:     decision,
+			Qty:      qty.InexactFloat64(),
+			Price:    price,
+			Score:    score,
+			Headline: m.Headline,
+			Time:     time.Now(),
+		}
+		if err := processor.book.Record(context.Background(), date, strat.Name, fill); err != nil {
+			log.Ctx(ctx).Error().Err(err).Str("strategy", strat.Name).Msg("failed to record the shadow fill")
+		}
+	}
+}
+
 // sentimentAnalysis calls the openAI sdk in order to get a sentiment analysis given a certain stock
 // it returns a response that matches the sentiment analysis. Also it returns an error if
 // it's not able to correctly process the input.
-func sentimentAnalysis(client *openai.Client, m models.Message) (int, error) {
+func sentimentAnalysis(ctx context.Context, client *openai.Client, prompt string, m models.Message) (int, error) {
 	resp, err := client.CreateChatCompletion(
 		context.Background(),
 		openai.ChatCompletionRequest{
@@ -99,7 +523,7 @@ func sentimentAnalysis(client *openai.Client, m models.Message) (int, error) {
 			Messages: []openai.ChatCompletionMessage{
 				{
 					Role:    openai.ChatMessageRoleUser,
-					Content: open_ai.Prompt + m.Headline,
+					Content: prompt + m.Headline,
 				},
 			},
 		},
@@ -110,7 +534,7 @@ func sentimentAnalysis(client *openai.Client, m models.Message) (int, error) {
 	}
 
 	result, err := strconv.Atoi(strings.TrimSpace(resp.Choices[0].Message.Content))
-	fmt.Println("The sentiment analysis is :", resp.Choices[0].Message.Content)
+	log.Ctx(ctx).Info().Str("response", resp.Choices[0].Message.Content).Msg("sentiment analysed")
 	if err != nil {
 		return 0, fmt.Errorf("conversion error: %v", err)
 	}